@@ -1,17 +1,30 @@
 package client
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/raphi011/handoff/internal/model"
 )
 
 type TestSuiteRun = model.TestSuiteRunHTTP
 type TestRun = model.TestRunHTTP
+type Schedule = model.ScheduledRun
+type RunEvent = model.RunEvent
+type TestSuiteRunFilter = model.TestSuiteRunFilter
+type Namespace = model.Namespace
+type NamespaceSuite = model.NamespaceSuite
+type TestFlakiness = model.TestFlakiness
+type SuiteDurationStats = model.SuiteDurationStats
 
 type Client struct {
 	http *http.Client
@@ -31,9 +44,41 @@ func New(host string, c *http.Client) Client {
 }
 
 func (c Client) CreateTestSuiteRun(ctx context.Context, suiteName string, filter *regexp.Regexp) (TestSuiteRun, error) {
-	url := c.url("/suites/%s/runs", suiteName)
+	query := url.Values{}
+	if filter != nil {
+		query.Set("filter", filter.String())
+	}
+
+	return c.createTestSuiteRun(ctx, suiteName, query)
+}
+
+// CreateLabeledTestSuiteRun starts a run of the tests of a test suite that match the label
+// selector, e.g. `smoke && !slow`. The other tests of the suite are skipped.
+func (c Client) CreateLabeledTestSuiteRun(ctx context.Context, suiteName string, labels string) (TestSuiteRun, error) {
+	return c.createTestSuiteRun(ctx, suiteName, url.Values{"labels": {labels}})
+}
+
+// CreateRepeatedTestSuiteRun starts a run that repeats the tests of a test suite (that
+// match the filter, if it is set) up to `repeat` times and stops at the first failure.
+// This helps to provoke and debug failures of flaky tests.
+func (c Client) CreateRepeatedTestSuiteRun(
+	ctx context.Context,
+	suiteName string,
+	filter *regexp.Regexp,
+	repeat int,
+) (TestSuiteRun, error) {
+	query := url.Values{"repeat": {strconv.Itoa(repeat)}}
 	if filter != nil {
-		url += fmt.Sprintf("?filter=%s", filter)
+		query.Set("filter", filter.String())
+	}
+
+	return c.createTestSuiteRun(ctx, suiteName, query)
+}
+
+func (c Client) createTestSuiteRun(ctx context.Context, suiteName string, query url.Values) (TestSuiteRun, error) {
+	url := c.url("/suites/%s/runs", suiteName)
+	if len(query) > 0 {
+		url += "?" + query.Encode()
 	}
 
 	req, err := http.NewRequest("POST", url, nil)
@@ -68,6 +113,270 @@ func (c Client) GetTestSuiteRun(ctx context.Context, suiteName string, runID int
 	return tsr, nil
 }
 
+// ListTestSuiteRuns returns the runs of a test suite that match the filter, ordered from the
+// newest to the oldest run. The returned cursor selects the next page and is 0 if there are
+// no more runs, a limit of 0 uses the default page size of the server.
+func (c Client) ListTestSuiteRuns(
+	ctx context.Context,
+	suiteName string,
+	filter TestSuiteRunFilter,
+	cursor, limit int,
+) ([]TestSuiteRun, int, error) {
+	query := filterQuery(filter, limit)
+	if cursor > 0 {
+		query.Set("cursor", strconv.Itoa(cursor))
+	}
+
+	req, err := http.NewRequest("GET", c.url("/suites/%s/runs", suiteName)+"?"+query.Encode(), nil)
+	if err != nil {
+		return []TestSuiteRun{}, 0, err
+	}
+
+	var runs []TestSuiteRun
+
+	header, err := c.doWithHeader(ctx, req, &runs)
+	if err != nil {
+		return []TestSuiteRun{}, 0, err
+	}
+
+	next := 0
+	if cursor := header.Get("X-Next-Cursor"); cursor != "" {
+		if next, err = strconv.Atoi(cursor); err != nil {
+			return []TestSuiteRun{}, 0, fmt.Errorf("invalid next cursor %q", cursor)
+		}
+	}
+
+	return runs, next, nil
+}
+
+// SearchTestSuiteRuns returns the runs of all test suites that match the filter, ordered
+// from the newest to the oldest run. The returned cursor selects the next page and is
+// empty if there are no more runs, a limit of 0 uses the default page size of the server.
+func (c Client) SearchTestSuiteRuns(
+	ctx context.Context,
+	filter TestSuiteRunFilter,
+	cursor string,
+	limit int,
+) ([]TestSuiteRun, string, error) {
+	query := filterQuery(filter, limit)
+	if cursor != "" {
+		query.Set("cursor", cursor)
+	}
+	if filter.Namespace != "" {
+		query.Set("namespace", filter.Namespace)
+	}
+	for _, suiteName := range filter.SuiteNames {
+		query.Add("suite", suiteName)
+	}
+
+	req, err := http.NewRequest("GET", c.url("/runs")+"?"+query.Encode(), nil)
+	if err != nil {
+		return []TestSuiteRun{}, "", err
+	}
+
+	var runs []TestSuiteRun
+
+	header, err := c.doWithHeader(ctx, req, &runs)
+	if err != nil {
+		return []TestSuiteRun{}, "", err
+	}
+
+	return runs, header.Get("X-Next-Cursor"), nil
+}
+
+// filterQuery returns the query params of a test suite run listing.
+func filterQuery(filter TestSuiteRunFilter, limit int) url.Values {
+	query := url.Values{}
+
+	for param, value := range map[string]string{
+		"result":          string(filter.Result),
+		"environment":     filter.Environment,
+		"initiated-by":    filter.InitiatedBy,
+		"reference":       filter.Reference,
+		"idempotency-key": filter.IdempotencyKey,
+		"test":            filter.TestName,
+	} {
+		if value != "" {
+			query.Set(param, value)
+		}
+	}
+	for key, value := range filter.Context {
+		query.Add("context", key+"="+value)
+	}
+	if !filter.From.IsZero() {
+		query.Set("from", filter.From.Format(time.RFC3339))
+	}
+	if !filter.To.IsZero() {
+		query.Set("to", filter.To.Format(time.RFC3339))
+	}
+	if filter.Flaky != nil {
+		query.Set("flaky", strconv.FormatBool(*filter.Flaky))
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+
+	return query
+}
+
+// ListNamespaces returns all namespaces with their test suites and the latest run of each
+// test suite.
+func (c Client) ListNamespaces(ctx context.Context) ([]Namespace, error) {
+	req, err := http.NewRequest("GET", c.url("/namespaces"), nil)
+	if err != nil {
+		return []Namespace{}, err
+	}
+
+	var namespaces []Namespace
+
+	if err := c.do(ctx, req, &namespaces); err != nil {
+		return []Namespace{}, err
+	}
+
+	return namespaces, nil
+}
+
+// ListFlakyTests returns the tests that passed and failed alternately in the recent runs
+// of the given test suites (all test suites if none are given), starting with the
+// flakiest test.
+func (c Client) ListFlakyTests(ctx context.Context, suiteNames ...string) ([]TestFlakiness, error) {
+	req, err := http.NewRequest("GET", c.url("/flaky")+"?"+url.Values{"suite": suiteNames}.Encode(), nil)
+	if err != nil {
+		return []TestFlakiness{}, err
+	}
+
+	var tests []TestFlakiness
+
+	if err := c.do(ctx, req, &tests); err != nil {
+		return []TestFlakiness{}, err
+	}
+
+	return tests, nil
+}
+
+// GetDurationStats returns the p50, p90 and maximum durations of the recent runs of a
+// test suite and of its tests.
+func (c Client) GetDurationStats(ctx context.Context, suiteName string) (SuiteDurationStats, error) {
+	req, err := http.NewRequest("GET", c.url("/suites/%s/durations", suiteName), nil)
+	if err != nil {
+		return SuiteDurationStats{}, err
+	}
+
+	var stats SuiteDurationStats
+
+	if err := c.do(ctx, req, &stats); err != nil {
+		return SuiteDurationStats{}, err
+	}
+
+	return stats, nil
+}
+
+// ListNamespaceSuites returns the test suites of a namespace and their latest run.
+func (c Client) ListNamespaceSuites(ctx context.Context, namespace string) ([]NamespaceSuite, error) {
+	req, err := http.NewRequest("GET", c.url("/namespaces/%s/suites", namespace), nil)
+	if err != nil {
+		return []NamespaceSuite{}, err
+	}
+
+	var suites []NamespaceSuite
+
+	if err := c.do(ctx, req, &suites); err != nil {
+		return []NamespaceSuite{}, err
+	}
+
+	return suites, nil
+}
+
+// CreateNamespaceRuns starts a run of every test suite of a namespace. If the filter is
+// set, only the tests that match it are run and suites without such tests are skipped.
+func (c Client) CreateNamespaceRuns(ctx context.Context, namespace string, filter *regexp.Regexp) ([]TestSuiteRun, error) {
+	query := url.Values{}
+	if filter != nil {
+		query.Set("filter", filter.String())
+	}
+
+	req, err := http.NewRequest("POST", c.url("/namespaces/%s/runs", namespace)+"?"+query.Encode(), nil)
+	if err != nil {
+		return []TestSuiteRun{}, err
+	}
+
+	var runs []TestSuiteRun
+
+	if err := c.do(ctx, req, &runs); err != nil {
+		return []TestSuiteRun{}, err
+	}
+
+	return runs, nil
+}
+
+func (c Client) CancelTestSuiteRun(ctx context.Context, suiteName string, runID int) error {
+	url := c.url("/suites/%s/runs/%d/cancel", suiteName, runID)
+
+	req, err := http.NewRequest("POST", url, nil)
+	if err != nil {
+		return err
+	}
+
+	return c.do(ctx, req, nil)
+}
+
+// RerunFailedTests starts a new run with the tests whose latest attempt failed in a
+// finished test suite run.
+func (c Client) RerunFailedTests(ctx context.Context, suiteName string, runID int) (TestSuiteRun, error) {
+	url := c.url("/suites/%s/runs/%d/rerun", suiteName, runID)
+
+	req, err := http.NewRequest("POST", url, nil)
+	if err != nil {
+		return TestSuiteRun{}, err
+	}
+
+	var tsr TestSuiteRun
+
+	if err := c.do(ctx, req, &tsr); err != nil {
+		return TestSuiteRun{}, err
+	}
+
+	return tsr, nil
+}
+
+// FollowTestSuiteRun streams the progress of a test suite run and calls `handle` for every
+// event until the run has finished or the context is cancelled.
+func (c Client) FollowTestSuiteRun(ctx context.Context, suiteName string, runID int, handle func(RunEvent)) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.url("/suites/%s/runs/%d/events", suiteName, runID), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Accept", "text/event-stream")
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return RequestError{res.StatusCode}
+	}
+
+	scanner := bufio.NewScanner(res.Body)
+
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+
+		var e RunEvent
+		if err := json.Unmarshal([]byte(data), &e); err != nil {
+			return err
+		}
+
+		handle(e)
+	}
+
+	return scanner.Err()
+}
+
 func (c Client) GetTestRun(ctx context.Context, suiteName string, runID int, testName string) ([]TestRun, error) {
 	url := c.url("/suites/%s/runs/%d/test/%s", suiteName, runID, testName)
 	req, err := http.NewRequest("GET", url, nil)
@@ -84,31 +393,163 @@ func (c Client) GetTestRun(ctx context.Context, suiteName string, runID int, tes
 	return tr, nil
 }
 
+// ImportTestSuiteRun imports the results of a test suite run that was executed outside of
+// handoff. Supported formats are `junit` (XML) and `gotest` (the output of `go test -json`).
+func (c Client) ImportTestSuiteRun(ctx context.Context, suiteName, format string, results io.Reader) (TestSuiteRun, error) {
+	query := url.Values{}
+	query.Set("format", format)
+
+	req, err := http.NewRequest("POST", c.url("/suites/%s/imports", suiteName)+"?"+query.Encode(), results)
+	if err != nil {
+		return TestSuiteRun{}, err
+	}
+
+	var tsr TestSuiteRun
+
+	if err = c.do(ctx, req, &tsr); err != nil {
+		return TestSuiteRun{}, err
+	}
+
+	return tsr, nil
+}
+
+func (c Client) CreateSchedule(ctx context.Context, scheduleName, suiteName, schedule string, filter *regexp.Regexp, maxRuns int) error {
+	query := url.Values{}
+	query.Set("suite", suiteName)
+	query.Set("schedule", schedule)
+	query.Set("max-runs", strconv.Itoa(maxRuns))
+	if filter != nil {
+		query.Set("filter", filter.String())
+	}
+
+	req, err := http.NewRequest("POST", c.url("/schedules/%s", scheduleName)+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+
+	return c.do(ctx, req, nil)
+}
+
+func (c Client) UpdateSchedule(ctx context.Context, scheduleName, schedule string, filter *regexp.Regexp, maxRuns int) error {
+	query := url.Values{}
+	query.Set("schedule", schedule)
+	query.Set("max-runs", strconv.Itoa(maxRuns))
+	if filter != nil {
+		query.Set("filter", filter.String())
+	}
+
+	req, err := http.NewRequest("PUT", c.url("/schedules/%s", scheduleName)+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+
+	return c.do(ctx, req, nil)
+}
+
+func (c Client) GetSchedules(ctx context.Context) ([]Schedule, error) {
+	req, err := http.NewRequest("GET", c.url("/schedules"), nil)
+	if err != nil {
+		return []Schedule{}, err
+	}
+
+	var schedules []Schedule
+
+	if err = c.do(ctx, req, &schedules); err != nil {
+		return []Schedule{}, err
+	}
+
+	return schedules, nil
+}
+
+func (c Client) GetSchedule(ctx context.Context, scheduleName string) (Schedule, error) {
+	req, err := http.NewRequest("GET", c.url("/schedules/%s", scheduleName), nil)
+	if err != nil {
+		return Schedule{}, err
+	}
+
+	var schedule Schedule
+
+	if err = c.do(ctx, req, &schedule); err != nil {
+		return Schedule{}, err
+	}
+
+	return schedule, nil
+}
+
+func (c Client) GetScheduleRuns(ctx context.Context, scheduleName string) ([]TestSuiteRun, error) {
+	req, err := http.NewRequest("GET", c.url("/schedules/%s/runs", scheduleName), nil)
+	if err != nil {
+		return []TestSuiteRun{}, err
+	}
+
+	var runs []TestSuiteRun
+
+	if err = c.do(ctx, req, &runs); err != nil {
+		return []TestSuiteRun{}, err
+	}
+
+	return runs, nil
+}
+
+func (c Client) PauseSchedule(ctx context.Context, scheduleName string) error {
+	req, err := http.NewRequest("POST", c.url("/schedules/%s/pause", scheduleName), nil)
+	if err != nil {
+		return err
+	}
+
+	return c.do(ctx, req, nil)
+}
+
+func (c Client) ResumeSchedule(ctx context.Context, scheduleName string) error {
+	req, err := http.NewRequest("POST", c.url("/schedules/%s/resume", scheduleName), nil)
+	if err != nil {
+		return err
+	}
+
+	return c.do(ctx, req, nil)
+}
+
+func (c Client) DeleteSchedule(ctx context.Context, scheduleName string) error {
+	req, err := http.NewRequest("DELETE", c.url("/schedules/%s", scheduleName), nil)
+	if err != nil {
+		return err
+	}
+
+	return c.do(ctx, req, nil)
+}
+
 func (c Client) url(path string, args ...any) string {
 	return fmt.Sprintf(c.host+path, args...)
 }
 
 func (c Client) do(ctx context.Context, req *http.Request, body any) error {
+	_, err := c.doWithHeader(ctx, req, body)
+
+	return err
+}
+
+// doWithHeader sends the request like `do()` and returns the header of the response.
+func (c Client) doWithHeader(ctx context.Context, req *http.Request, body any) (http.Header, error) {
 	req = req.WithContext(ctx)
 	req.Header.Add("Accept", "application/json")
 
 	res, err := c.http.Do(req)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer res.Body.Close()
 
 	if res.StatusCode < 200 || res.StatusCode >= 300 {
-		return RequestError{res.StatusCode}
+		return nil, RequestError{res.StatusCode}
 	}
 
 	if body != nil {
 		d := json.NewDecoder(res.Body)
 
 		if err = d.Decode(body); err != nil {
-			return err
+			return nil, err
 		}
 	}
 
-	return nil
+	return res.Header, nil
 }
@@ -10,11 +10,18 @@ import (
 	"github.com/raphi011/handoff"
 	"github.com/stretchr/testify/assert"
 
+	_ "github.com/jackc/pgx/v5/stdlib"
 	_ "github.com/raphi011/handoff/internal/packagetestexample"
+	_ "modernc.org/sqlite"
 )
 
 func main() {
 	h := handoff.New(
+		handoff.WithScheduledRun(handoff.ScheduledRun{
+			Name:          "external-suite-hourly",
+			TestSuiteName: "external-suite-succeed",
+			Schedule:      "@every 1h",
+		}),
 		handoff.WithTestSuite(handoff.TestSuite{
 			Name: "soft-failure",
 			Tests: []handoff.TestFunc{
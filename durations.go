@@ -0,0 +1,74 @@
+package handoff
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/raphi011/handoff/internal/model"
+)
+
+// durationStats computes the duration statistics of a test suite and its tests from its
+// recent finished runs. The run with the id `excludeRunID` is not part of the statistics,
+// so that a run is not compared with itself.
+func (s *Server) durationStats(ctx context.Context, suiteName string, excludeRunID int) (model.SuiteDurationStats, error) {
+	window := s.config.DurationWindow
+
+	page := model.Page{}
+	if window > 0 {
+		// one more run is loaded in case the excluded run is part of the window.
+		page.Limit = window + 1
+	}
+
+	runs, _, err := s.storage.ListTestSuiteRuns(
+		ctx,
+		suiteName,
+		model.TestSuiteRunFilter{Results: []model.Result{model.ResultPassed, model.ResultFailed}},
+		page,
+	)
+	if err != nil {
+		return model.SuiteDurationStats{}, fmt.Errorf("loading runs of %s: %w", suiteName, err)
+	}
+
+	runs = slices.DeleteFunc(runs, func(tsr model.TestSuiteRun) bool {
+		return tsr.ID == excludeRunID
+	})
+
+	if window > 0 && len(runs) > window {
+		runs = runs[:window]
+	}
+
+	return model.ComputeDurationStats(suiteName, runs), nil
+}
+
+// setProgress estimates the progress of a pending run.
+func (s *Server) setProgress(ctx context.Context, tsr *model.TestSuiteRun) error {
+	if tsr.Result != model.ResultPending {
+		return nil
+	}
+
+	stats, err := s.durationStats(ctx, tsr.SuiteName, tsr.ID)
+	if err != nil {
+		return err
+	}
+
+	tsr.Progress = model.EstimateProgress(*tsr, stats)
+
+	return nil
+}
+
+// isSlowRun returns true if a finished run took significantly longer than the previous
+// runs of its test suite.
+func (s *Server) isSlowRun(ctx context.Context, tsr model.TestSuiteRun) bool {
+	if tsr.Result != model.ResultPassed && tsr.Result != model.ResultFailed {
+		return false
+	}
+
+	stats, err := s.durationStats(ctx, tsr.SuiteName, tsr.ID)
+	if err != nil {
+		s.log.Warn("computing duration baseline failed", "suite-name", tsr.SuiteName, "run-id", tsr.ID, "error", err)
+		return false
+	}
+
+	return stats.Suite.IsSlow(tsr.DurationInMS, s.config.SlowRunFactor)
+}
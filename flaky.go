@@ -0,0 +1,87 @@
+package handoff
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/raphi011/handoff/internal/model"
+)
+
+// analyzeFlakiness computes the flakiness of the tests of a test suite from its recent
+// runs.
+func (s *Server) analyzeFlakiness(ctx context.Context, suiteName string) ([]model.TestFlakiness, error) {
+	runs, _, err := s.storage.ListTestSuiteRuns(
+		ctx,
+		suiteName,
+		model.TestSuiteRunFilter{},
+		model.Page{Limit: s.config.FlakyWindow},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("loading runs of %s: %w", suiteName, err)
+	}
+
+	flakiness := model.AnalyzeFlakiness(runs, model.FlakinessParams{
+		Threshold: s.config.FlakyThreshold,
+		MinRuns:   s.config.FlakyMinRuns,
+	})
+
+	// external test suites are not run by handoff so their tests can not be quarantined.
+	_, internal := s.readOnlyTestSuites[suiteName]
+
+	for i := range flakiness {
+		flakiness[i].Quarantined = flakiness[i].Flaky && internal && s.config.QuarantineFlakyTests
+	}
+
+	return flakiness, nil
+}
+
+// flakinessReport returns the tests of the test suites that failed and passed
+// alternately in their recent runs, starting with the flakiest test.
+func (s *Server) flakinessReport(ctx context.Context, suiteNames []string) ([]model.TestFlakiness, error) {
+	report := []model.TestFlakiness{}
+
+	for _, suiteName := range suiteNames {
+		flakiness, err := s.analyzeFlakiness(ctx, suiteName)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, f := range flakiness {
+			if f.Flips > 0 {
+				report = append(report, f)
+			}
+		}
+	}
+
+	sort.SliceStable(report, func(i, j int) bool {
+		return report[i].FlipRate > report[j].FlipRate
+	})
+
+	return report, nil
+}
+
+// quarantinedTests returns the names of the tests of a test suite whose failures are
+// treated as soft failures because they are flaky. Quarantining is best effort, if the
+// history can not be analyzed no test is quarantined.
+func (s *Server) quarantinedTests(ctx context.Context, suiteName string) map[string]bool {
+	quarantined := map[string]bool{}
+
+	if !s.config.QuarantineFlakyTests {
+		return quarantined
+	}
+
+	flakiness, err := s.analyzeFlakiness(ctx, suiteName)
+	if err != nil {
+		s.log.Warn("analyzing flaky tests failed, no tests are quarantined", "suite-name", suiteName, "error", err)
+		return quarantined
+	}
+
+	for _, f := range flakiness {
+		if f.Quarantined {
+			quarantined[f.TestName] = true
+		}
+	}
+
+	return quarantined
+}
@@ -11,7 +11,10 @@ import (
 	"os"
 	"os/signal"
 	"reflect"
+	"regexp"
 	"runtime"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
 	"syscall"
@@ -40,10 +43,28 @@ type Server struct {
 	// initialisation.
 	readOnlySchedules []model.ScheduledRun
 
+	// schedules contains all static and persisted scheduled runs keyed by
+	// their name.
+	schedules     map[string]model.ScheduledRun
+	schedulesLock sync.Mutex
+
+	// externalTestSuites contains the test suites whose results are imported
+	// keyed by their name.
+	externalTestSuites     map[string]model.ExternalTestSuite
+	externalTestSuitesLock sync.Mutex
+
 	// _userProvidedTestSuites is a list of all test suites provided
 	// by the user and will be mapped to `readOnlyTestSuites` on startup.
 	_userProvidedTestSuites []TestSuite
 
+	// _userProvidedSchedules is a list of all scheduled runs provided
+	// by the user and will be mapped to `readOnlySchedules` on startup.
+	_userProvidedSchedules []ScheduledRun
+
+	// _userProvidedHooks is a list of all hooks provided by the user
+	// and will be registered with `hooks` on startup.
+	_userProvidedHooks []Hook
+
 	// started will be closed when the service has started.
 	started chan any
 
@@ -58,11 +79,27 @@ type Server struct {
 
 	runningTestSuites sync.WaitGroup
 
+	// runCtx is the parent context of all test suite runs, it is
+	// cancelled with `errServerShutdown` once the server shuts down.
+	runCtx       context.Context
+	cancelRunCtx context.CancelCauseFunc
+
+	// queue limits the number of concurrently running test suite runs.
+	queue *runQueue
+
+	// activeRuns contains the cancel functions of all test suite runs
+	// that are currently executed by this server, keyed by `activeRunKey()`.
+	activeRuns     map[string]context.CancelCauseFunc
+	activeRunsLock sync.Mutex
+
+	// events streams the progress of running test suite runs.
+	events *runEventBroker
+
 	httpServer *http.Server
 
 	log *slog.Logger
 
-	storage *storage.BadgerStorage
+	storage storage.Storage
 }
 
 type config struct {
@@ -73,13 +110,18 @@ type config struct {
 	// Port for the web api
 	Port int `arg:"-p,env:HANDOFF_SERVER_PORT" help:"port used by the server (server mode only)" default:"1337"`
 
+	// WriteTimeout is the maximum duration of writing a response, streams of run events
+	// are not affected by it.
+	WriteTimeout time.Duration `arg:"--write-timeout,env:HANDOFF_WRITE_TIMEOUT" help:"maximum duration of writing a response (run event streams are exempt)" default:"31s"`
+
 	RunTTL time.Duration `arg:"-t,--ttl,env:HANDOFF_RUN_TTL" help:"test suite run retention TTL" default:"0"`
 
 	EnablePprof bool `arg:"--enable-pprof" help:"enable pprof debugging endpoints" default:"false"`
 
-	// location of the sqlite database file, if empty we default
-	// to an in-memory database.
-	DatabaseFilePath string `arg:"-d,--database,env:HANDOFF_DATABASE" help:"database file location" default:"handoff.db"`
+	// location of the database, either the path of a badger database, a
+	// sqlite://<path> or a postgres:// url. If empty we default to an
+	// in-memory database.
+	Database string `arg:"-d,--database,env:HANDOFF_DATABASE" help:"database location, either a badger database path, a sqlite://<path> or a postgres:// url" default:"handoff.db"`
 
 	// List will, if set to true, print all loaded test suites
 	// and immediately exit.
@@ -87,6 +129,54 @@ type config struct {
 
 	JsonLogging bool `arg:"-j,--jsonlog" help:"enables json log format" default:"false"`
 
+	// Headless runs the test suites once without starting the web server and cron,
+	// the server exits with an error if any of the test suite runs did not pass.
+	Headless bool `arg:"--headless,env:HANDOFF_HEADLESS" help:"run the test suites once and exit without starting the server" default:"false"`
+
+	// Run selects the test suites by name or namespace that are run in headless mode,
+	// setting it implies headless mode.
+	Run []string `arg:"--run,env:HANDOFF_RUN" help:"names or namespaces of the test suites to run in headless mode"`
+
+	// RunFilter is a regex that filters the tests that are run in headless mode.
+	RunFilter string `arg:"--filter,env:HANDOFF_FILTER" help:"regex that filters the tests to run in headless mode"`
+
+	// RunLabels is a label selector that selects the tests that are run in headless mode.
+	RunLabels string `arg:"--labels,env:HANDOFF_LABELS" help:"label selector (e.g. 'smoke && !slow') of the tests to run in headless mode"`
+
+	// Reports are file paths the results of the headless test suite runs are written to,
+	// the format is chosen by the file extension.
+	Reports []string `arg:"--report,separate,env:HANDOFF_REPORT" help:"file the results are written to in headless mode (.json or junit .xml), can be repeated"`
+
+	// MaxRunningTestSuites limits the number of test suite runs that are executed at the
+	// same time, further runs are queued.
+	MaxRunningTestSuites int `arg:"--max-running,env:HANDOFF_MAX_RUNNING" help:"maximum number of concurrently running test suite runs, 0 means unlimited" default:"0"`
+
+	// MaxRunningTestSuitesPerNamespace is like MaxRunningTestSuites but per namespace.
+	MaxRunningTestSuitesPerNamespace int `arg:"--max-running-per-namespace,env:HANDOFF_MAX_RUNNING_PER_NAMESPACE" help:"maximum number of concurrently running test suite runs per namespace, 0 means unlimited" default:"0"`
+
+	// FlakyWindow is the number of recent runs of a test suite that are analyzed to
+	// detect flaky tests.
+	FlakyWindow int `arg:"--flaky-window,env:HANDOFF_FLAKY_WINDOW" help:"number of recent runs per test suite that are analyzed to detect flaky tests" default:"50"`
+
+	// FlakyThreshold is the flip rate between passed and failed attempts above which
+	// a test is considered flaky.
+	FlakyThreshold float64 `arg:"--flaky-threshold,env:HANDOFF_FLAKY_THRESHOLD" help:"share of attempts whose result differs from the previous attempt above which a test is flaky" default:"0.2"`
+
+	// FlakyMinRuns is the number of runs a test must have finished in before it can be
+	// considered flaky.
+	FlakyMinRuns int `arg:"--flaky-min-runs,env:HANDOFF_FLAKY_MIN_RUNS" help:"minimum number of runs before a test can be considered flaky" default:"5"`
+
+	// QuarantineFlakyTests runs flaky tests but treats their failures as soft failures.
+	QuarantineFlakyTests bool `arg:"--quarantine-flaky,env:HANDOFF_QUARANTINE_FLAKY" help:"treat failures of flaky tests as soft failures" default:"false"`
+
+	// DurationWindow is the number of recent runs of a test suite whose durations are
+	// used to estimate the progress of runs and detect slow runs.
+	DurationWindow int `arg:"--duration-window,env:HANDOFF_DURATION_WINDOW" help:"number of recent runs per test suite whose durations are used as the baseline" default:"50"`
+
+	// SlowRunFactor flags runs that take longer than this factor times the 90th
+	// percentile of the durations of previous runs.
+	SlowRunFactor float64 `arg:"--slow-run-factor,env:HANDOFF_SLOW_RUN_FACTOR" help:"runs that take longer than this factor times the p90 duration of previous runs are slow, 0 disables it" default:"1.5"`
+
 	// Environment is e.g. the cluster/platform the tests are run on.
 	// This is added to metrics and the testrun information.
 	Environment string `arg:"-e,--env,env:HANDOFF_ENVIRONMENT" help:"the environment where the tests are run"`
@@ -113,35 +203,95 @@ type TestSuite struct {
 	Description     string
 	Setup           func() error
 	Teardown        func() error
-	Timeout         time.Duration
-	Tests           []TestFunc
+	// SetupWithContext is like Setup but receives the context of the run, values it sets
+	// (e.g. the id of a tenant created for the run) are visible to every test via
+	// `t.Value()`. Only one of Setup and SetupWithContext can be set.
+	SetupWithContext func(c *RunContext) error
+	// TeardownWithContext is like Teardown but receives the context of the run with
+	// the values set during the setup. Only one of Teardown and TeardownWithContext can be set.
+	TeardownWithContext func(c *RunContext) error
+	// FailOnTeardownError fails a run if its teardown fails, by default a failed teardown
+	// is only recorded on the run.
+	FailOnTeardownError bool
+	// Timeout is the maximum duration of an entire test suite run,
+	// tests that are still running when it expires are failed.
+	Timeout time.Duration
+	// TestTimeout is the maximum duration of a single test. It can be
+	// overridden per run or by the test itself via `t.SetTimeout()`.
+	TestTimeout time.Duration
+	// Parallelism limits how many tests that opted into running in parallel
+	// via `t.Parallel()` run at the same time. If it is 0 or 1 they are run
+	// one after another.
+	Parallelism int
+	Tests       []TestFunc
+	// Labels are attached to every test of the suite.
+	Labels []string
+	// TestLabels attaches labels to single tests, keyed by the name of the test function.
+	TestLabels map[string][]string
+}
+
+// ScheduledRun represents the external view of a schedule that periodically starts
+// runs of a test suite. Scheduled runs passed in when bootstrapping the server are
+// static and cannot be modified or deleted through the api.
+type ScheduledRun struct {
+	// Name of the schedule, it must be unique.
+	Name string
+	// TestSuiteName is the name of the test suite to be run.
+	TestSuiteName string
+	// Schedule is a cron expression (with seconds) or a descriptor like `@every 1h`.
+	// For the format see https://pkg.go.dev/github.com/robfig/cron#hdr-CRON_Expression_Format
+	Schedule string
+	// TestFilter optionally limits the run to the tests matching the filter.
+	TestFilter *regexp.Regexp
+	// Labels optionally limits the run to the tests matching the label selector,
+	// e.g. `smoke && !slow`.
+	Labels string
+	// MaxRuns limits how often the schedule starts a run, 0 means forever.
+	MaxRuns int
 }
 
 // Reexport to allow library users to reference these types
 type TestFunc = model.TestFunc
 type TB = model.TB
 type TestContext = model.TestContext
+type RunContext = model.RunContext
 
 type Option func(s *Server)
 
-var registeredSuites []TestSuite
+var (
+	registeredSuites    []TestSuite
+	registeredSchedules []ScheduledRun
+)
 
-// Register registers test suites and schedules for the handoff server. Has to be called
+// RegisterSuites registers test suites for the handoff server. Has to be called
 // before `handoff.New()` to take effect.
 func RegisterSuites(suites []TestSuite) {
 	registeredSuites = append(registeredSuites, suites...)
 }
 
+// RegisterSchedules registers scheduled runs for the handoff server. Has to be called
+// before `handoff.New()` to take effect.
+func RegisterSchedules(schedules []ScheduledRun) {
+	registeredSchedules = append(registeredSchedules, schedules...)
+}
+
 // New configures a new Handoff instance.
 func New(opts ...Option) *Server {
 	s := &Server{
 		_userProvidedTestSuites: registeredSuites,
+		_userProvidedSchedules:  registeredSchedules,
 		readOnlyTestSuites:      map[string]model.TestSuite{},
 		started:                 make(chan any),
 		hasShutdown:             make(chan error, 1),
 		shutdown:                make(chan any),
+		activeRuns:              map[string]context.CancelCauseFunc{},
+		schedules:               map[string]model.ScheduledRun{},
+		externalTestSuites:      map[string]model.ExternalTestSuite{},
+		events:                  newRunEventBroker(),
 	}
 
+	s.runCtx, s.cancelRunCtx = context.WithCancelCause(context.Background())
+
 	for _, o := range opts {
 		o(s)
 	}
@@ -171,6 +321,14 @@ func (s *Server) Run(args []string) error {
 	}
 
 	s.hooks = newHookManager(s.asyncHookCallback, s.log)
+	s.hooks.all = append(s.hooks.all, s._userProvidedHooks...)
+
+	s.queue = newRunQueue(
+		s.config.MaxRunningTestSuites,
+		s.config.MaxRunningTestSuitesPerNamespace,
+		s.config.Instance,
+		s.runTestSuiteAsync,
+	)
 
 	s.signalHandler()
 
@@ -178,16 +336,22 @@ func (s *Server) Run(args []string) error {
 		return err
 	}
 
+	if err := s.mapSchedules(); err != nil {
+		return err
+	}
+
 	if s.config.ListTestSuites {
 		s.printTestSuites()
 	}
 
 	s.cron = cron.New(cron.WithSeconds())
-	s.cron.Start()
+	if !s.config.headless() {
+		s.cron.Start()
+	}
 
-	storage, err := storage.NewBadgerStorage(s.config.DatabaseFilePath, s.config.RunTTL, s.cron, s.log)
+	storage, err := storage.Open(s.config.Database, s.config.RunTTL, s.cron, s.log)
 	if err != nil {
-		return fmt.Errorf("init badger storage: %w", err)
+		return fmt.Errorf("init storage: %w", err)
 	}
 	s.storage = storage
 
@@ -195,11 +359,22 @@ func (s *Server) Run(args []string) error {
 		return fmt.Errorf("init hooks: %w", err)
 	}
 
-	// TODO: also start schedules from DB
+	if s.config.headless() {
+		return s.runHeadless()
+	}
+
+	if err := s.loadExternalTestSuites(context.Background()); err != nil {
+		return fmt.Errorf("load external test suites: %w", err)
+	}
+
 	if err := s.startStaticSchedules(); err != nil {
 		return fmt.Errorf("start schedules: %w", err)
 	}
 
+	if err := s.startPersistedSchedules(context.Background()); err != nil {
+		return fmt.Errorf("start persisted schedules: %w", err)
+	}
+
 	if err = s.runHTTP(); err != nil {
 		return fmt.Errorf("start http server: %w", err)
 	}
@@ -285,6 +460,16 @@ func (s *Server) resumePendingTestRuns() {
 		return
 	}
 
+	// runs that were interrupted go first, the rest is queued again
+	// in the order they were originally scheduled.
+	sort.SliceStable(pendingRuns, func(i, j int) bool {
+		if pendingRuns[i].Start.IsZero() != pendingRuns[j].Start.IsZero() {
+			return !pendingRuns[i].Start.IsZero()
+		}
+
+		return pendingRuns[i].Scheduled.Before(pendingRuns[j].Scheduled)
+	})
+
 	continued := 0
 
 	for _, tsr := range pendingRuns {
@@ -297,7 +482,7 @@ func (s *Server) resumePendingTestRuns() {
 
 		continued++
 
-		go s.runTestSuite(testSuite, tsr)
+		s.queue.enqueue(testSuite, tsr)
 	}
 
 	if continued > 0 {
@@ -306,21 +491,33 @@ func (s *Server) resumePendingTestRuns() {
 }
 
 func (s *Server) gracefulShutdown() {
-	httpStopped := s.stopHTTP()
+	var (
+		httpErr     error
+		httpStopped chan error
+	)
+
+	// the http server is not started in headless mode
+	if s.httpServer != nil {
+		httpStopped = s.stopHTTP()
+	}
 	cronStopCtx := s.cron.Stop()
 
-	httpErr := <-httpStopped
-	s.log.Info("Http listener stopped")
+	if httpStopped != nil {
+		httpErr = <-httpStopped
+		s.log.Info("Http listener stopped")
+	}
 	<-cronStopCtx.Done()
 	s.log.Info("Scheduled tests stopped")
 
+	s.queue.close()
+	s.cancelRunCtx(errServerShutdown)
+	s.runningTestSuites.Wait()
+	s.log.Info("Running test suites finished")
+
 	pluginStopCtx := s.hooks.shutdown()
 	<-pluginStopCtx.Done()
 	s.log.Info("Plugins stopped")
 
-	s.runningTestSuites.Wait()
-	s.log.Info("Running test suites finished")
-
 	dbErr := s.storage.Close()
 	s.log.Info("DB closed")
 
@@ -345,58 +542,13 @@ func (s *Server) printTestSuites() {
 		}
 	}
 
-	fmt.Print(b.String())
-
-	os.Exit(0)
-}
-
-func (s *Server) startStaticSchedules() error {
 	for _, sr := range s.readOnlySchedules {
-
-		if _, err := s.startSchedule(sr, false); err != nil {
-			return err
-		}
-	}
-
-	return nil
-}
-
-func (s *Server) startSchedule(sr model.ScheduledRun, persist bool) (cron.EntryID, error) {
-	ts, ok := s.readOnlyTestSuites[sr.TestSuiteName]
-	if !ok {
-		return -1, fmt.Errorf("test suite %q not found", sr.TestSuiteName)
-	}
-
-	if len(ts.FilterTests(sr.TestFilter)) == 0 {
-		return -1, errors.New("no tests match filter regex %s")
+		b.WriteString("schedule: \"" + sr.Name + "\" runs \"" + sr.TestSuiteName + "\" (" + sr.Schedule + ")\n")
 	}
 
-	ctx := context.Background()
-
-	if persist {
-		err := s.storage.InsertScheduledRun(ctx, sr)
-		if err != nil {
-			return -1, fmt.Errorf("persisting schedule failed: %w", err)
-		}
-	}
-
-	entryID, err := s.cron.AddFunc(sr.Schedule, func() {
-		_, err := s.startNewTestSuiteRun(ts, model.RunParams{
-			InitiatedBy:     "scheduled-run",
-			TestFilter:      sr.TestFilter,
-			MaxTestAttempts: ts.MaxTestAttempts,
-		})
-		if err != nil {
-			s.log.Error("starting new scheduled test suite run failed", "error", err, "test-suite", ts.Name)
-		}
-	})
-	if err != nil {
-		// TODO: log this error?
-		_ = s.storage.DeleteScheduledRun(ctx, sr.Name)
-		return -1, fmt.Errorf("adding scheduled test suite run %q: %w", sr.TestSuiteName, err)
-	}
+	fmt.Print(b.String())
 
-	return entryID, nil
+	os.Exit(0)
 }
 
 // startNewTestSuiteRun is used to start new test suite runs. It persists the
@@ -415,6 +567,10 @@ func (s *Server) startNewTestSuiteRun(ts model.TestSuite, option model.RunParams
 		option.MaxTestAttempts = ts.MaxTestAttempts
 	}
 
+	if option.Timeout == 0 {
+		option.Timeout = ts.TestTimeout
+	}
+
 	ctx := context.Background()
 
 	if option.IdempotencyKey != "" {
@@ -443,24 +599,38 @@ func (s *Server) startNewTestSuiteRun(ts model.TestSuite, option model.RunParams
 		Scheduled:      time.Now(),
 		Environment:    s.config.Environment,
 		InitiatedBy:    option.InitiatedBy,
+		ScheduleName:   option.ScheduleName,
+		ParentRunID:    option.ParentRunID,
 		IdempotencyKey: option.IdempotencyKey,
 		Reference:      option.Reference,
 	}
 
+	if len(option.Tests) > 0 {
+		tsr.Tests = len(option.Tests)
+	}
+
+	quarantined := s.quarantinedTests(ctx, ts.Name)
+
 	for testName := range ts.Tests {
+		if len(option.Tests) > 0 && !slices.Contains(option.Tests, testName) {
+			continue
+		}
+
 		result := model.ResultPending
 
-		if tsr.Params.TestFilter != nil && !tsr.Params.TestFilter.MatchString(testName) {
+		if !ts.Selected(testName, tsr.Params.TestFilter, tsr.Params.Labels) {
 			result = model.ResultSkipped
 		}
 
 		tr := model.TestRun{
-			SuiteName:  tsr.SuiteName,
-			SuiteRunID: tsr.ID,
-			Name:       testName,
-			Result:     result,
-			Attempt:    1,
-			Context:    model.TestContext{},
+			SuiteName:   tsr.SuiteName,
+			SuiteRunID:  tsr.ID,
+			Name:        testName,
+			Result:      result,
+			Attempt:     1,
+			Context:     model.TestContext{},
+			Labels:      ts.Labels[testName],
+			Quarantined: quarantined[testName],
 		}
 
 		tsr.TestResults = append(tsr.TestResults, tr)
@@ -486,13 +656,88 @@ func (s *Server) startNewTestSuiteRun(ts model.TestSuite, option model.RunParams
 
 	tsrCopy := tsr.Copy()
 
-	go s.runTestSuite(ts, tsr)
+	s.queue.enqueue(ts, tsr)
+
+	tsrCopy.QueuePosition = s.queue.position(tsr.SuiteName, tsr.ID)
 
 	// return a copy otherwise we might get a data race when marshalling the testresults
 	// for http response body and running the tests at the same time.
 	return tsrCopy, nil
 }
 
+// runTestSuiteAsync registers a test suite run as active so that it can be cancelled
+// and executes it in a separate goroutine. It must only be called by the `runQueue`.
+func (s *Server) runTestSuiteAsync(ts model.TestSuite, tsr model.TestSuiteRun) {
+	ctx, cancel := context.WithCancelCause(s.runCtx)
+
+	key := activeRunKey(tsr.SuiteName, tsr.ID)
+
+	s.activeRunsLock.Lock()
+	s.activeRuns[key] = cancel
+	s.activeRunsLock.Unlock()
+
+	s.runningTestSuites.Add(1)
+
+	go func() {
+		defer s.runningTestSuites.Done()
+
+		defer func() {
+			s.activeRunsLock.Lock()
+			delete(s.activeRuns, key)
+			s.activeRunsLock.Unlock()
+
+			cancel(nil)
+
+			s.queue.finished(ts.Namespace)
+		}()
+
+		s.runTestSuite(ctx, ts, tsr)
+	}()
+}
+
+func activeRunKey(suiteName string, runID int) string {
+	return fmt.Sprintf("%s-%d", suiteName, runID)
+}
+
+// cancelTestSuiteRun cancels a pending test suite run. If the run is currently executed
+// the running test is interrupted, the remaining tests are skipped and the teardown is run
+// before the run is persisted with the `cancelled` result.
+func (s *Server) cancelTestSuiteRun(ctx context.Context, suiteName string, runID int) error {
+	// a run that is removed from the queue has never been started so there
+	// is no need to interrupt it.
+	if !s.queue.remove(suiteName, runID) {
+		s.activeRunsLock.Lock()
+		cancel, ok := s.activeRuns[activeRunKey(suiteName, runID)]
+		s.activeRunsLock.Unlock()
+
+		if ok {
+			cancel(errRunCancelled)
+			return nil
+		}
+	}
+
+	tsr, err := s.storage.LoadTestSuiteRun(ctx, suiteName, runID)
+	if err != nil {
+		return err
+	}
+
+	if tsr.Result != model.ResultPending {
+		return conflictError{reason: fmt.Sprintf("test suite run has already finished with result %q", tsr.Result)}
+	}
+
+	// the run is either queued or pending but not executed by this server,
+	// e.g. because its test suite was removed, so there is nothing to interrupt.
+	skipPendingTests(&tsr, fmt.Sprintf("%v: skipped", errRunCancelled))
+	tsr.Result = model.ResultCancelled
+	tsr.End = time.Now()
+
+	if err := s.storage.UpdateTestSuiteRun(ctx, tsr); err != nil {
+		return fmt.Errorf("persisting cancelled test suite run: %w", err)
+	}
+
+	return nil
+}
+
 func (s *Server) isShuttingDown() bool {
 	select {
 	case <-s.shutdown:
@@ -524,25 +769,117 @@ func (h *Server) mapTestSuites() error {
 		}
 
 		mappedTs := model.TestSuite{
-			Name:            ts.Name,
-			Namespace:       ts.Namespace,
-			MaxTestAttempts: ts.MaxTestAttempts,
-			Setup:           ts.Setup,
-			Description:     ts.Description,
-			Teardown:        ts.Teardown,
-			Tests:           make(map[string]model.TestFunc),
+			Name:                ts.Name,
+			Namespace:           ts.Namespace,
+			MaxTestAttempts:     ts.MaxTestAttempts,
+			Setup:               ts.SetupWithContext,
+			Description:         ts.Description,
+			Teardown:            ts.TeardownWithContext,
+			FailOnTeardownError: ts.FailOnTeardownError,
+			Timeout:             ts.Timeout,
+			TestTimeout:         ts.TestTimeout,
+			Parallelism:         ts.Parallelism,
+			Tests:               make(map[string]model.TestFunc),
+			Labels:              make(map[string][]string),
 		}
 
 		for _, t := range ts.Tests {
 			mappedTs.Tests[testName(t)] = t
 		}
 
+		if ts.Setup != nil {
+			if ts.SetupWithContext != nil {
+				return fmt.Errorf("test suite %s has both Setup and SetupWithContext set", ts.Name)
+			}
+
+			mappedTs.Setup = func(*model.RunContext) error { return ts.Setup() }
+		}
+
+		if ts.Teardown != nil {
+			if ts.TeardownWithContext != nil {
+				return fmt.Errorf("test suite %s has both Teardown and TeardownWithContext set", ts.Name)
+			}
+
+			mappedTs.Teardown = func(*model.RunContext) error { return ts.Teardown() }
+		}
+
+		if err := mapTestLabels(ts, mappedTs); err != nil {
+			return fmt.Errorf("test suite %s: %w", ts.Name, err)
+		}
+
 		h.readOnlyTestSuites[mappedTs.Name] = mappedTs
 	}
 
 	return nil
 }
 
+// mapTestLabels merges the labels of a suite with the labels of its tests.
+func mapTestLabels(ts TestSuite, mappedTs model.TestSuite) error {
+	for name, labels := range ts.TestLabels {
+		if _, ok := mappedTs.Tests[name]; !ok {
+			return fmt.Errorf("labels of unknown test %s", name)
+		}
+
+		for _, label := range labels {
+			if !model.ValidLabel(label) {
+				return fmt.Errorf("invalid label %q of test %s", label, name)
+			}
+		}
+	}
+
+	for _, label := range ts.Labels {
+		if !model.ValidLabel(label) {
+			return fmt.Errorf("invalid label %q", label)
+		}
+	}
+
+	for name := range mappedTs.Tests {
+		labels := slices.Concat(ts.Labels, ts.TestLabels[name])
+		slices.Sort(labels)
+
+		mappedTs.Labels[name] = slices.Compact(labels)
+	}
+
+	return nil
+}
+
+func (h *Server) mapSchedules() error {
+	names := map[string]bool{}
+
+	for _, sr := range h._userProvidedSchedules {
+		if names[sr.Name] {
+			return fmt.Errorf("duplicate scheduled run with name %s", sr.Name)
+		}
+		names[sr.Name] = true
+
+		mappedSr := model.ScheduledRun{
+			Name:          sr.Name,
+			TestSuiteName: sr.TestSuiteName,
+			Schedule:      sr.Schedule,
+			TestFilter:    sr.TestFilter,
+			MaxRuns:       sr.MaxRuns,
+			Static:        true,
+		}
+
+		if sr.Labels != "" {
+			labels, err := model.ParseLabelSelector(sr.Labels)
+			if err != nil {
+				return fmt.Errorf("scheduled run %s is invalid: %w", sr.Name, err)
+			}
+
+			mappedSr.Labels = labels
+		}
+
+		if _, err := h.validateSchedule(mappedSr); err != nil {
+			return fmt.Errorf("scheduled run %s is invalid: %w", sr.Name, err)
+		}
+
+		h.readOnlySchedules = append(h.readOnlySchedules, mappedSr)
+	}
+
+	return nil
+}
+
 func testName(tf TestFunc) string {
 	fullFuncName := runtime.FuncForPC(reflect.ValueOf(tf).Pointer()).Name()
 
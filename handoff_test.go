@@ -2,14 +2,22 @@ package handoff_test
 
 import (
 	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
 	"regexp"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/raphi011/handoff"
 	"github.com/raphi011/handoff/client"
+	"github.com/raphi011/handoff/internal/junit"
 	"github.com/raphi011/handoff/internal/model"
 	"github.com/stretchr/testify/assert"
 )
@@ -58,6 +66,250 @@ func TestSuiteNeedsRetrySucceedsOnTheSecondAttempt(t *testing.T) {
 	assert.Equal(t, model.ResultPassed, tr.Result, "expected test run to have passed")
 }
 
+func TestTestSuiteRunAsJUnitXML(t *testing.T) {
+	t.Parallel()
+
+	suiteName := "needs-retry"
+
+	tsr := te.createNewTestSuiteRun(t, suiteName)
+	te.waitForTestSuiteRunWithResult(t, defaultTimeout, suiteName, tsr.ID, model.ResultPassed)
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("http://localhost:%d/suites/%s/runs/%d", te.h.ServerPort(), suiteName, tsr.ID), nil)
+	assert.NoError(t, err)
+	req.Header.Set("Accept", "application/xml")
+
+	res, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err, "get test suite run as junit should succeed")
+	defer res.Body.Close()
+
+	assert.Equal(t, "application/xml", res.Header.Get("Content-Type"))
+
+	var suites junit.Testsuites
+	assert.NoError(t, xml.NewDecoder(res.Body).Decode(&suites), "expected valid junit xml")
+	assert.Len(t, suites.Suites, 1)
+
+	ts := suites.Suites[0]
+	assert.Equal(t, suiteName, ts.Name)
+	assert.Equal(t, 1, ts.Tests, "expected retried test to be reported once")
+	assert.Equal(t, 0, ts.Failures)
+	assert.Len(t, ts.Testcases, 1)
+	assert.Nil(t, ts.Testcases[0].Failure)
+	assert.Len(t, ts.Testcases[0].FlakyFailures, 1, "expected failed attempt to be reported as flaky failure")
+}
+
+func TestBrowsersAreServedHTML(t *testing.T) {
+	t.Parallel()
+
+	suiteName := "succeed"
+
+	tsr := te.createNewTestSuiteRun(t, suiteName)
+	te.waitForTestSuiteRunWithResult(t, defaultTimeout, suiteName, tsr.ID, model.ResultPassed)
+
+	for _, path := range []string{
+		"/suites",
+		"/schedules",
+		fmt.Sprintf("/suites/%s/runs", suiteName),
+		fmt.Sprintf("/suites/%s/runs/%d", suiteName, tsr.ID),
+	} {
+		req, err := http.NewRequest("GET", fmt.Sprintf("http://localhost:%d%s", te.h.ServerPort(), path), nil)
+		assert.NoError(t, err)
+		req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,*/*;q=0.8")
+
+		res, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err, "get %s should succeed", path)
+
+		body, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, res.StatusCode, "expected %s to succeed", path)
+		assert.Equal(t, "text/html", res.Header.Get("Content-Type"), "expected %s to be rendered as html", path)
+		assert.Contains(t, string(body), "<html", "expected %s to render a page", path)
+	}
+}
+
+func TestSoftFailuresArePassedTestsInJUnitXML(t *testing.T) {
+	t.Parallel()
+
+	suiteName := "soft-fail"
+
+	tsr := te.createNewTestSuiteRun(t, suiteName)
+	te.waitForTestSuiteRunWithResult(t, defaultTimeout, suiteName, tsr.ID, model.ResultPassed)
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("http://localhost:%d/suites/%s/runs/%d", te.h.ServerPort(), suiteName, tsr.ID), nil)
+	assert.NoError(t, err)
+	req.Header.Set("Accept", "application/xml")
+
+	res, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err, "get test suite run as junit should succeed")
+	defer res.Body.Close()
+
+	var suites junit.Testsuites
+	assert.NoError(t, xml.NewDecoder(res.Body).Decode(&suites), "expected valid junit xml")
+	assert.Equal(t, 0, suites.Failures, "expected soft failures to not fail the report")
+
+	for _, tc := range suites.Suites[0].Testcases {
+		assert.Nil(t, tc.Failure, "expected %s to be reported as passed", tc.Name)
+
+		if tc.Name == "SoftFail" {
+			assert.Equal(t, []junit.Property{{Name: "softFailure", Value: "true"}}, tc.Properties)
+		}
+	}
+}
+
+func TestImportJUnitResults(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	report := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuite name="checkout" tests="3" failures="1" skipped="1" time="1.5" timestamp="2024-01-02T10:00:00">
+  <testcase name="testPay" classname="com.shop.CheckoutTest" time="1.0">
+    <flakyFailure message="timeout"/>
+  </testcase>
+  <testcase name="testRefund" classname="com.shop.CheckoutTest" time="0.5">
+    <failure message="expected 200">stack trace</failure>
+  </testcase>
+  <testcase name="testVoucher" classname="com.shop.CheckoutTest" time="0">
+    <skipped message="not implemented"/>
+  </testcase>
+</testsuite>`
+
+	tsr, err := te.client.ImportTestSuiteRun(ctx, "java-checkout", "junit", strings.NewReader(report))
+	assert.NoError(t, err, "importing junit results should succeed")
+	assert.Equal(t, model.ResultFailed, tsr.Result)
+	assert.Equal(t, 3, tsr.Tests)
+
+	tsr, err = te.client.GetTestSuiteRun(ctx, "java-checkout", tsr.ID)
+	assert.NoError(t, err, "imported test suite run should be persisted")
+
+	tr := latestTestAttempt(t, tsr, "com.shop.CheckoutTest.testPay")
+	assert.Equal(t, 2, tr.Attempt, "expected flaky failure to be imported as previous attempt")
+	assert.Equal(t, model.ResultPassed, tr.Result)
+
+	tr = latestTestAttempt(t, tsr, "com.shop.CheckoutTest.testRefund")
+	assert.Equal(t, model.ResultFailed, tr.Result)
+	assert.Contains(t, tr.Logs, "stack trace")
+
+	tr = latestTestAttempt(t, tsr, "com.shop.CheckoutTest.testVoucher")
+	assert.Equal(t, model.ResultSkipped, tr.Result)
+}
+
+func TestImportGoTestResults(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	events := `{"Time":"2024-01-02T10:00:00Z","Action":"run","Package":"example.com/e2e","Test":"TestLogin"}
+{"Time":"2024-01-02T10:00:00Z","Action":"output","Package":"example.com/e2e","Test":"TestLogin","Output":"login ok\n"}
+{"Time":"2024-01-02T10:00:01Z","Action":"pass","Package":"example.com/e2e","Test":"TestLogin","Elapsed":1}
+{"Time":"2024-01-02T10:00:01Z","Action":"run","Package":"example.com/e2e","Test":"TestLogout"}
+{"Time":"2024-01-02T10:00:02Z","Action":"fail","Package":"example.com/e2e","Test":"TestLogout","Elapsed":1}
+{"Time":"2024-01-02T10:00:02Z","Action":"fail","Package":"example.com/e2e","Elapsed":2}
+`
+
+	tsr, err := te.client.ImportTestSuiteRun(ctx, "go-e2e", "gotest", strings.NewReader(events))
+	assert.NoError(t, err, "importing go test results should succeed")
+	assert.Equal(t, model.ResultFailed, tsr.Result)
+
+	tr := latestTestAttempt(t, tsr, "TestLogin")
+	assert.Equal(t, model.ResultPassed, tr.Result)
+	assert.Equal(t, "login ok\n", tr.Logs)
+
+	tr = latestTestAttempt(t, tsr, "TestLogout")
+	assert.Equal(t, model.ResultFailed, tr.Result)
+
+	_, err = te.client.ImportTestSuiteRun(ctx, "succeed", "gotest", strings.NewReader(events))
+	var reqError client.RequestError
+	assert.ErrorAs(t, err, &reqError, "expected error of type RequestError")
+	assert.Equal(t, http.StatusConflict, reqError.ResponseCode, "expected import into a native test suite to conflict")
+}
+
+func TestImportOfTooLargeResultsIsRejected(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	// one byte more than the import limit of 32 MiB.
+	results := strings.NewReader(strings.Repeat("\n", 32<<20+1))
+
+	_, err := te.client.ImportTestSuiteRun(ctx, "too-large-import", "gotest", results)
+
+	var reqError client.RequestError
+
+	assert.ErrorAs(t, err, &reqError, "expected error of type RequestError")
+	assert.Equal(t, http.StatusRequestEntityTooLarge, reqError.ResponseCode)
+
+	_, err = te.client.GetTestSuiteRun(ctx, "too-large-import", 1)
+	assert.ErrorAs(t, err, &reqError, "expected the rejected import not to be persisted")
+	assert.Equal(t, http.StatusNotFound, reqError.ResponseCode)
+}
+
+func TestFollowTestSuiteRunStreamsEvents(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	suiteName := "slow-log"
+
+	tsr := te.createNewTestSuiteRun(t, suiteName)
+
+	var events []client.RunEvent
+
+	err := te.client.FollowTestSuiteRun(ctx, suiteName, tsr.ID, func(e client.RunEvent) {
+		events = append(events, e)
+	})
+	assert.NoError(t, err, "following the test suite run should succeed")
+
+	assert.NotEmpty(t, events, "expected events to be streamed")
+
+	var logs []string
+	for _, e := range events {
+		if e.Type == model.RunEventLog {
+			logs = append(logs, e.Log)
+		}
+	}
+	assert.Equal(t, []string{"streamed log"}, logs)
+
+	last := events[len(events)-1]
+	assert.Equal(t, model.RunEventRunFinished, last.Type, "expected the stream to end with the run-finished event")
+	assert.Equal(t, model.ResultPassed, last.Result)
+
+	// following a finished run only returns its result
+	events = nil
+	err = te.client.FollowTestSuiteRun(ctx, suiteName, tsr.ID, func(e client.RunEvent) {
+		events = append(events, e)
+	})
+	assert.NoError(t, err, "following a finished test suite run should succeed")
+	assert.Len(t, events, 1)
+}
+
+func TestRunEventStreamOutlivesTheWriteTimeout(t *testing.T) {
+	t.Parallel()
+
+	suite := handoff.TestSuite{
+		Name:  "slow-stream",
+		Tests: []model.TestFunc{Sleep(time.Second)},
+	}
+
+	i := handoffInstance([]handoff.TestSuite{suite}, []string{"handoff-test", "-p", "0", "-d", "", "--write-timeout", "200ms"})
+	defer i.h.Shutdown()
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	tsr := i.createNewTestSuiteRun(t, "slow-stream")
+
+	var last client.RunEvent
+
+	err := i.client.FollowTestSuiteRun(ctx, "slow-stream", tsr.ID, func(e client.RunEvent) {
+		last = e
+	})
+	assert.NoError(t, err, "following the test suite run should succeed")
+	assert.Equal(t, model.RunEventRunFinished, last.Type, "expected the stream to last until the run has finished")
+}
+
 func TestSuiteWithFailingSetupSkipsTestsAndFails(t *testing.T) {
 	t.Parallel()
 
@@ -75,6 +327,32 @@ func TestSuiteWithFailingSetupSkipsTestsAndFails(t *testing.T) {
 	assert.Equal(t, "test suite run setup failed: skipped", tr.Logs, "expected test run to contain setup failed log")
 }
 
+func TestFailingTeardownIsRecordedOnTheRun(t *testing.T) {
+	t.Parallel()
+
+	suiteName := "failing-teardown"
+
+	tsr := te.createNewTestSuiteRun(t, suiteName)
+
+	tsr = te.waitForTestSuiteRunWithResult(t, defaultTimeout, suiteName, tsr.ID, model.ResultPassed)
+	assert.Equal(t, "creating tenant\n", tsr.SetupLogs, "expected the setup logs to be persisted")
+	assert.Equal(t, model.ResultFailed, tsr.TeardownResult, "expected the teardown to fail")
+	assert.Equal(t, "deleting tenant\nteardown failed: tenant not found", tsr.TeardownLogs, "expected the teardown logs to contain the error")
+}
+
+func TestFailingTeardownFailsRunIfConfigured(t *testing.T) {
+	t.Parallel()
+
+	suiteName := "strict-failing-teardown"
+
+	tsr := te.createNewTestSuiteRun(t, suiteName)
+
+	tsr = te.waitForTestSuiteRunWithResult(t, defaultTimeout, suiteName, tsr.ID, model.ResultFailed)
+	assert.Equal(t, model.ResultFailed, tsr.TeardownResult, "expected the teardown to fail")
+	assert.Equal(t, "tenant not found\nteardown failed: marked as failed", tsr.TeardownLogs)
+	assert.Equal(t, model.ResultPassed, latestTestAttempt(t, tsr, "Success").Result, "expected the test to pass")
+}
+
 func TestSuiteWithPanicingSetupSkipsTestsAndFails(t *testing.T) {
 	t.Parallel()
 
@@ -92,6 +370,357 @@ func TestSuiteWithPanicingSetupSkipsTestsAndFails(t *testing.T) {
 	assert.Equal(t, "test suite run setup failed: skipped", tr.Logs, "expected test run to contain setup failed log")
 }
 
+func TestTestExceedingTimeoutIsCancelledAndFails(t *testing.T) {
+	t.Parallel()
+
+	suiteName := "test-timeout"
+
+	tsr := te.createNewTestSuiteRun(t, suiteName)
+
+	tsr = te.waitForTestSuiteRunWithResult(t, defaultTimeout, suiteName, tsr.ID, model.ResultFailed)
+
+	tr := latestTestAttempt(t, tsr, "WaitForCancellation")
+	assert.Equal(t, model.ResultFailed, tr.Result, "expected test run to have failed")
+	assert.Contains(t, tr.Logs, "context canceled", "expected test to observe the cancelled context")
+	assert.Contains(t, tr.Logs, "test timed out after 100ms", "expected test run logs to contain the timeout")
+}
+
+func TestHangingTestDoesNotBlockTheSuiteRun(t *testing.T) {
+	t.Parallel()
+
+	suiteName := "hanging-test"
+
+	tsr := te.createNewTestSuiteRun(t, suiteName)
+
+	tsr = te.waitForTestSuiteRunWithResult(t, defaultTimeout, suiteName, tsr.ID, model.ResultFailed)
+
+	tr := latestTestAttempt(t, tsr, "Sleep")
+	assert.Equal(t, model.ResultFailed, tr.Result, "expected test run to have failed")
+	assert.Contains(t, tr.Logs, "test timed out after 100ms", "expected test run logs to contain the timeout")
+	assert.Contains(t, tr.Logs, "did not return", "expected test run logs to mention the abandoned test")
+}
+
+func TestSuiteExceedingTimeoutFails(t *testing.T) {
+	t.Parallel()
+
+	suiteName := "suite-timeout"
+
+	tsr := te.createNewTestSuiteRun(t, suiteName)
+
+	tsr = te.waitForTestSuiteRunWithResult(t, defaultTimeout, suiteName, tsr.ID, model.ResultFailed)
+
+	tr := latestTestAttempt(t, tsr, "WaitForCancellation")
+	assert.Contains(t, tr.Logs, "test suite run timed out after 100ms", "expected test run logs to contain the suite timeout")
+}
+
+func TestParallelTestsRunConcurrently(t *testing.T) {
+	t.Parallel()
+
+	suiteName := "parallel"
+
+	tsr := te.createNewTestSuiteRun(t, suiteName)
+
+	tsr = te.waitForTestSuiteRunWithResult(t, defaultTimeout, suiteName, tsr.ID, model.ResultPassed)
+	assert.Less(t, tsr.End.Sub(tsr.Start), time.Second, "expected parallel tests to run concurrently")
+
+	tr := latestTestAttempt(t, tsr, "Retry")
+	assert.Equal(t, 2, tr.Attempt, "expected sequential test to be retried")
+}
+
+func TestWaitingParallelTestsDoNotTimeOut(t *testing.T) {
+	t.Parallel()
+
+	suiteName := "parallel-timeout"
+
+	tsr := te.createNewTestSuiteRun(t, suiteName)
+
+	tsr = te.waitForTestSuiteRunWithResult(t, defaultTimeout, suiteName, tsr.ID, model.ResultPassed)
+
+	tr := latestTestAttempt(t, tsr, "ParallelSleep")
+	assert.Equal(t, model.ResultPassed, tr.Result, "expected parallel test to pass after waiting for the sequential test")
+	assert.NotContains(t, tr.Logs, "test timed out", "expected the wait to not count towards the timeout")
+}
+
+func TestCancelledTestSuiteRunSkipsRemainingTests(t *testing.T) {
+	t.Parallel()
+
+	suiteName := "cancellable"
+
+	tsr := te.createNewTestSuiteRun(t, suiteName)
+
+	err := te.client.CancelTestSuiteRun(context.Background(), suiteName, tsr.ID)
+	assert.NoError(t, err, "cancelling test suite run should succeed")
+
+	tsr = te.waitForTestSuiteRunWithResult(t, defaultTimeout, suiteName, tsr.ID, model.ResultCancelled)
+
+	tr := latestTestAttempt(t, tsr, "WaitForCancellation")
+	assert.Equal(t, model.ResultSkipped, tr.Result, "expected interrupted test to be skipped")
+	assert.Contains(t, tr.Logs, "test suite run was cancelled", "expected test run logs to contain the cancellation reason")
+
+	err = te.client.CancelTestSuiteRun(context.Background(), suiteName, tsr.ID)
+
+	var reqError client.RequestError
+
+	assert.ErrorAs(t, err, &reqError, "expected error of type RequestError")
+	assert.Equal(t, http.StatusConflict, reqError.ResponseCode, "expected cancelling a finished run to conflict")
+}
+
+func TestRerunFailedTestsOfARun(t *testing.T) {
+	t.Parallel()
+
+	suite := handoff.TestSuite{
+		Name:  "rerun",
+		Tests: []model.TestFunc{Success, Fail, SoftFail},
+	}
+
+	i := handoffInstance([]handoff.TestSuite{suite}, []string{"handoff-test", "-p", "0", "-d", ""})
+	defer i.h.Shutdown()
+
+	ctx := context.Background()
+
+	tsr := i.createNewTestSuiteRun(t, "rerun")
+	tsr = i.waitForTestSuiteRunWithResult(t, defaultTimeout, "rerun", tsr.ID, model.ResultFailed)
+
+	rerun, err := i.client.RerunFailedTests(ctx, "rerun", tsr.ID)
+	assert.NoError(t, err, "rerunning the failed tests should succeed")
+	assert.Equal(t, tsr.ID, rerun.ParentRunID, "expected the rerun to reference the original run")
+
+	rerun = i.waitForTestSuiteRunWithResult(t, defaultTimeout, "rerun", rerun.ID, model.ResultFailed)
+
+	tests := []string{}
+	for _, tr := range rerun.TestResults {
+		tests = append(tests, tr.Name)
+	}
+
+	assert.ElementsMatch(t, []string{"Fail", "SoftFail"}, tests, "expected only the failed tests to be rerun")
+	assert.Equal(t, 2, rerun.Tests)
+
+	_, err = i.client.RerunFailedTests(ctx, "rerun", 42)
+
+	var reqError client.RequestError
+
+	assert.ErrorAs(t, err, &reqError, "expected error of type RequestError")
+	assert.Equal(t, http.StatusNotFound, reqError.ResponseCode, "expected rerunning an unknown run to fail")
+
+	passed := te.createNewTestSuiteRun(t, "succeed")
+	passed = te.waitForTestSuiteRunWithResult(t, defaultTimeout, "succeed", passed.ID, model.ResultPassed)
+
+	_, err = te.client.RerunFailedTests(ctx, "succeed", passed.ID)
+
+	assert.ErrorAs(t, err, &reqError, "expected error of type RequestError")
+	assert.Equal(t, http.StatusConflict, reqError.ResponseCode, "expected rerunning a run without failed tests to conflict")
+}
+
+func TestFlakyTestsAreDetectedAndQuarantined(t *testing.T) {
+	t.Parallel()
+
+	suite := handoff.TestSuite{
+		Name:  "flip-flop",
+		Tests: []model.TestFunc{Success, Alternate()},
+	}
+
+	i := handoffInstance(
+		[]handoff.TestSuite{suite},
+		[]string{"handoff-test", "-p", "0", "-d", "", "--flaky-min-runs", "3", "--quarantine-flaky"},
+	)
+	defer i.h.Shutdown()
+
+	for _, result := range []model.Result{model.ResultPassed, model.ResultFailed, model.ResultPassed} {
+		tsr := i.createNewTestSuiteRun(t, "flip-flop")
+		i.waitForTestSuiteRunWithResult(t, defaultTimeout, "flip-flop", tsr.ID, result)
+	}
+
+	flaky, err := i.client.ListFlakyTests(context.Background())
+	assert.NoError(t, err, "fetching the flaky tests should succeed")
+	assert.Len(t, flaky, 1, "expected only the alternating test to be reported")
+	assert.Equal(t, "Alternate", flaky[0].TestName)
+	assert.Equal(t, 3, flaky[0].Runs)
+	assert.Equal(t, 1, flaky[0].Failures)
+	assert.Equal(t, 1.0, flaky[0].FlipRate)
+	assert.True(t, flaky[0].Flaky, "expected the test to be flaky")
+	assert.True(t, flaky[0].Quarantined, "expected the test to be quarantined")
+
+	// the failure of the quarantined test is a soft failure that does not fail the run.
+	tsr := i.createNewTestSuiteRun(t, "flip-flop")
+	tsr = i.waitForTestSuiteRunWithResult(t, defaultTimeout, "flip-flop", tsr.ID, model.ResultPassed)
+
+	tr := latestTestAttempt(t, tsr, "Alternate")
+	assert.Equal(t, model.ResultFailed, tr.Result)
+	assert.True(t, tr.Quarantined, "expected the test run to be quarantined")
+	assert.True(t, tr.SoftFailure, "expected the failure to be a soft failure")
+}
+
+func TestRepeatedRunStopsAtTheFirstFailure(t *testing.T) {
+	t.Parallel()
+
+	suite := handoff.TestSuite{
+		Name:            "repeat",
+		MaxTestAttempts: 3,
+		Tests:           []model.TestFunc{Success, FailOnCall(3)},
+	}
+
+	i := handoffInstance([]handoff.TestSuite{suite}, []string{"handoff-test", "-p", "0", "-d", ""})
+	defer i.h.Shutdown()
+
+	ctx := context.Background()
+
+	tsr, err := i.client.CreateRepeatedTestSuiteRun(ctx, "repeat", nil, 10)
+	assert.NoError(t, err, "starting a repeated run should succeed")
+
+	tsr = i.waitForTestSuiteRunWithResult(t, defaultTimeout, "repeat", tsr.ID, model.ResultFailed)
+	assert.True(t, tsr.Flaky, "expected a test that failed after passing to be flaky")
+
+	failed := latestTestAttempt(t, tsr, "FailOnCall")
+	assert.Equal(t, 3, failed.Attempt, "expected the run to stop at the iteration that failed")
+	assert.Equal(t, model.ResultFailed, failed.Result)
+	assert.Contains(t, failed.Logs, "failed on call 3", "expected the logs of the failure to be kept")
+
+	assert.Equal(t, 3, latestTestAttempt(t, tsr, "Success").Attempt, "expected the other tests to stop as well")
+
+	tsr, err = i.client.CreateRepeatedTestSuiteRun(ctx, "repeat", regexp.MustCompile("Success"), 5)
+	assert.NoError(t, err, "starting a repeated run should succeed")
+
+	tsr = i.waitForTestSuiteRunWithResult(t, defaultTimeout, "repeat", tsr.ID, model.ResultPassed)
+	assert.Equal(t, 5, latestTestAttempt(t, tsr, "Success").Attempt, "expected passing tests to be repeated")
+	assert.False(t, tsr.Flaky)
+
+	_, err = i.client.CreateRepeatedTestSuiteRun(ctx, "repeat", nil, -1)
+
+	var reqError client.RequestError
+
+	assert.ErrorAs(t, err, &reqError, "expected error of type RequestError")
+	assert.Equal(t, http.StatusBadRequest, reqError.ResponseCode, "expected a negative repeat to be rejected")
+}
+
+func TestDurationStatsEstimateProgressAndFlagSlowRuns(t *testing.T) {
+	t.Parallel()
+
+	suite := handoff.TestSuite{
+		Name:  "durations",
+		Tests: []model.TestFunc{Success, SlowOnCall(model.MinBaselineRuns+1, time.Second)},
+	}
+
+	i := handoffInstance([]handoff.TestSuite{suite}, []string{"handoff-test", "-p", "0", "-d", ""})
+	defer i.h.Shutdown()
+
+	ctx := context.Background()
+
+	for range model.MinBaselineRuns {
+		tsr := i.createNewTestSuiteRun(t, "durations")
+		tsr = i.waitForTestSuiteRunWithResult(t, defaultTimeout, "durations", tsr.ID, model.ResultPassed)
+		assert.False(t, tsr.Slow, "expected runs without a baseline not to be slow")
+	}
+
+	stats, err := i.client.GetDurationStats(ctx, "durations")
+	assert.NoError(t, err, "fetching the duration stats should succeed")
+	assert.Equal(t, model.MinBaselineRuns, stats.Suite.Runs)
+	assert.Equal(t, model.MinBaselineRuns, stats.Tests["SlowOnCall"].Runs)
+	assert.Equal(t, model.MinBaselineRuns, stats.Tests["Success"].Runs)
+
+	tsr := i.createNewTestSuiteRun(t, "durations")
+
+	pending, err := i.client.GetTestSuiteRun(ctx, "durations", tsr.ID)
+	assert.NoError(t, err)
+	if assert.NotNil(t, pending.Progress, "expected the progress of the pending run to be estimated") {
+		assert.Equal(t, model.MinBaselineRuns, pending.Progress.Typical.Runs)
+	}
+
+	tsr = i.waitForTestSuiteRunWithResult(t, defaultTimeout, "durations", tsr.ID, model.ResultPassed)
+	assert.True(t, tsr.Slow, "expected the run to be slower than its baseline")
+	assert.Nil(t, tsr.Progress, "expected finished runs to have no progress")
+
+	_, err = i.client.GetDurationStats(ctx, "unknown")
+
+	var reqError client.RequestError
+
+	assert.ErrorAs(t, err, &reqError, "expected error of type RequestError")
+	assert.Equal(t, http.StatusNotFound, reqError.ResponseCode)
+}
+
+func TestTestLevelMetricsAreExposed(t *testing.T) {
+	t.Parallel()
+
+	// the metrics are registered globally, unique suite names keep the values of
+	// previous invocations of the test (e.g. with -count) apart.
+	suiteName := fmt.Sprintf("metrics-%d", time.Now().UnixNano())
+	repeatSuiteName := suiteName + "-repeat"
+
+	suites := []handoff.TestSuite{
+		{
+			Name:            suiteName,
+			MaxTestAttempts: 2,
+			Tests:           []model.TestFunc{Retry(1), CreateSpan, CreateManySpans},
+		},
+		{
+			Name:  repeatSuiteName,
+			Tests: []model.TestFunc{Success},
+		},
+	}
+
+	i := handoffInstance(suites, []string{"handoff-test", "-p", "0", "-d", ""})
+	defer i.h.Shutdown()
+
+	tsr := i.createNewTestSuiteRun(t, suiteName)
+	i.waitForTestSuiteRunWithResult(t, defaultTimeout, suiteName, tsr.ID, model.ResultPassed)
+
+	tsr, err := i.client.CreateRepeatedTestSuiteRun(context.Background(), repeatSuiteName, nil, 3)
+	assert.NoError(t, err, "creating a repeated run should succeed")
+	i.waitForTestSuiteRunWithResult(t, defaultTimeout, repeatSuiteName, tsr.ID, model.ResultPassed)
+
+	res, err := http.Get(fmt.Sprintf("http://localhost:%d/metrics", i.h.ServerPort()))
+	assert.NoError(t, err, "fetching the metrics should succeed")
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	assert.NoError(t, err)
+
+	for _, metric := range []*regexp.Regexp{
+		regexp.MustCompile(fmt.Sprintf(`handoff_test_attempts_total{.*result="failed",suite_name="%s",test_name="Retry"} 1`, suiteName)),
+		regexp.MustCompile(fmt.Sprintf(`handoff_test_attempts_total{.*result="passed",suite_name="%s",test_name="Retry"} 1`, suiteName)),
+		regexp.MustCompile(fmt.Sprintf(`handoff_test_retries_total{.*suite_name="%s",test_name="Retry"} 1`, suiteName)),
+		regexp.MustCompile(fmt.Sprintf(`handoff_test_duration_seconds_count{.*result="failed",suite_name="%s",test_name="Retry"} 1`, suiteName)),
+		regexp.MustCompile(fmt.Sprintf(`handoff_test_span_duration_seconds_count{.*span_name="login",suite_name="%s",test_name="CreateSpan"} 1`, suiteName)),
+		regexp.MustCompile(fmt.Sprintf(`handoff_test_span_duration_seconds_count{.*span_name="request-19",suite_name="%s",test_name="CreateManySpans"} 1`, suiteName)),
+		regexp.MustCompile(fmt.Sprintf(`handoff_test_span_duration_seconds_count{.*span_name="other",suite_name="%s",test_name="CreateManySpans"} 1`, suiteName)),
+		regexp.MustCompile(fmt.Sprintf(`handoff_test_last_result{.*suite_name="%s",test_name="Retry"} 1`, suiteName)),
+		regexp.MustCompile(fmt.Sprintf(`handoff_test_last_success_timestamp_seconds{.*suite_name="%s",test_name="CreateSpan"} \d`, suiteName)),
+		regexp.MustCompile(fmt.Sprintf(`handoff_testsuite_duration_seconds_count{.*result="passed",suite_name="%s"} 1`, suiteName)),
+	} {
+		assert.Regexp(t, metric, string(body), "expected the metric to be exposed")
+	}
+
+	assert.Regexp(t, fmt.Sprintf(`handoff_test_attempts_total{.*suite_name="%s",test_name="Success"} 3`, repeatSuiteName), string(body))
+	assert.NotRegexp(t, fmt.Sprintf(`handoff_test_retries_total{.*suite_name="%s"`, repeatSuiteName), string(body), "expected repeated attempts to not be counted as retries")
+}
+
+func TestFinishedTestsArePersistedWhileTheRunIsPending(t *testing.T) {
+	t.Parallel()
+
+	suiteName := "partial-progress"
+
+	tsr := te.createNewTestSuiteRun(t, suiteName)
+
+	assert.Eventually(t, func() bool {
+		run, err := te.client.GetTestSuiteRun(context.Background(), suiteName, tsr.ID)
+		if err != nil || run.Result != model.ResultPending {
+			return false
+		}
+
+		for _, tr := range run.TestResults {
+			if tr.Name == "Success" {
+				return tr.Result == model.ResultPassed
+			}
+		}
+
+		return false
+	}, defaultTimeout, 50*time.Millisecond, "expected the finished test to be persisted before the run has finished")
+
+	err := te.client.CancelTestSuiteRun(context.Background(), suiteName, tsr.ID)
+	assert.NoError(t, err, "cancelling test suite run should succeed")
+
+	te.waitForTestSuiteRunWithResult(t, defaultTimeout, suiteName, tsr.ID, model.ResultCancelled)
+}
+
 func TestSuiteRegisteredByExternalPackage(t *testing.T) {
 	t.Parallel()
 
@@ -140,6 +769,186 @@ func TestTestSuiteValidation(t *testing.T) {
 	assert.Error(t, err, "Passing in multiple test suite with the same name should fail")
 }
 
+func TestHeadlessRunExitsWithResult(t *testing.T) {
+	t.Parallel()
+
+	suites := []handoff.Option{
+		handoff.WithTestSuite(handoff.TestSuite{
+			Name:      "success",
+			Namespace: "team-a",
+			Tests:     []model.TestFunc{Success},
+		}),
+		handoff.WithTestSuite(handoff.TestSuite{
+			Name:  "failure",
+			Tests: []model.TestFunc{Success, Fail},
+		}),
+	}
+
+	report := filepath.Join(t.TempDir(), "report.json")
+
+	h := handoff.New(suites...)
+	err := h.Run([]string{"handoff-test", "-d", "", "--run", "team-a", "--report", report})
+	assert.NoError(t, err, "expected headless run of passing test suites to succeed")
+
+	f, err := os.Open(report)
+	assert.NoError(t, err, "expected report to be written")
+	defer f.Close()
+
+	var runs []model.TestSuiteRun
+	assert.NoError(t, json.NewDecoder(f).Decode(&runs), "expected report to be valid json")
+	assert.Len(t, runs, 1, "expected only the selected test suite to be run")
+	assert.Equal(t, "success", runs[0].SuiteName)
+	assert.Equal(t, model.ResultPassed, runs[0].Result)
+
+	h = handoff.New(suites...)
+	err = h.Run([]string{"handoff-test", "-d", "", "--headless"})
+	assert.Error(t, err, "expected headless run with a failing test suite to fail")
+
+	h = handoff.New(suites...)
+	err = h.Run([]string{"handoff-test", "-d", "", "--run", "failure", "--filter", "Success"})
+	assert.NoError(t, err, "expected headless run with filtered out failing test to succeed")
+
+	h = handoff.New(suites...)
+	err = h.Run([]string{"handoff-test", "-d", "", "--run", "unknown"})
+	assert.Error(t, err, "expected headless run of an unknown test suite to fail")
+}
+
+func TestRunTestsSelectedByLabels(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	suite := handoff.TestSuite{
+		Name:   "labeled",
+		Tests:  []model.TestFunc{Success, Fail, SoftFail},
+		Labels: []string{"payments"},
+		TestLabels: map[string][]string{
+			"Success":  {"smoke"},
+			"SoftFail": {"smoke", "slow"},
+		},
+	}
+
+	i := handoffInstance([]handoff.TestSuite{suite}, []string{"handoff-test", "-p", "0", "-d", ""})
+	defer i.h.Shutdown()
+
+	tsr, err := i.client.CreateLabeledTestSuiteRun(ctx, "labeled", "smoke && !slow")
+	assert.NoError(t, err, "starting a run selected by labels should succeed")
+
+	tsr = i.waitForTestSuiteRunWithResult(t, defaultTimeout, "labeled", tsr.ID, model.ResultPassed)
+	assert.Equal(t, model.ResultPassed, latestTestAttempt(t, tsr, "Success").Result)
+	assert.Equal(t, []string{"payments", "smoke"}, latestTestAttempt(t, tsr, "Success").Labels)
+	assert.Equal(t, model.ResultSkipped, latestTestAttempt(t, tsr, "Fail").Result)
+	assert.Equal(t, model.ResultSkipped, latestTestAttempt(t, tsr, "SoftFail").Result)
+
+	var reqError client.RequestError
+
+	for _, labels := range []string{"smoke ||", "nightly", "(smoke"} {
+		_, err = i.client.CreateLabeledTestSuiteRun(ctx, "labeled", labels)
+		assert.ErrorAs(t, err, &reqError, "expected error of type RequestError for %q", labels)
+		assert.Equal(t, http.StatusBadRequest, reqError.ResponseCode, "labels %q", labels)
+	}
+
+	h := handoff.New(handoff.WithTestSuite(suite))
+	err = h.Run([]string{"handoff-test", "-d", "", "--run", "labeled", "--labels", "payments && !(smoke || slow)"})
+	assert.Error(t, err, "expected headless run of the failing test selected by labels to fail")
+
+	h = handoff.New(handoff.WithTestSuite(suite))
+	err = h.Run([]string{"handoff-test", "-d", "", "--run", "labeled", "--labels", "smoke"})
+	assert.NoError(t, err, "expected headless run of the smoke tests to succeed")
+
+	suite.TestLabels = map[string][]string{"Unknown": {"smoke"}}
+
+	h = handoff.New(handoff.WithTestSuite(suite))
+	err = h.Run([]string{"handoff-test", "-d", "", "--headless"})
+	assert.Error(t, err, "expected labels of unknown tests to be rejected")
+}
+
+// runContextHook records the run context of finished test suite runs.
+type runContextHook struct {
+	finished chan model.TestContext
+}
+
+func (h runContextHook) Name() string { return "run-context" }
+func (h runContextHook) Init() error  { return nil }
+
+func (h runContextHook) TestSuiteFinished(suite model.TestSuite, run model.TestSuiteRun) {
+	h.finished <- run.Context
+}
+
+func TestSetupSharesValuesWithTestsAndTeardown(t *testing.T) {
+	t.Parallel()
+
+	teardownValue := make(chan any, 1)
+	hook := runContextHook{finished: make(chan model.TestContext, 1)}
+
+	suite := handoff.TestSuite{
+		Name: "setup-context",
+		SetupWithContext: func(c *handoff.RunContext) error {
+			c.SetValue("tenant-id", fmt.Sprintf("tenant-%s-%d", c.SuiteName(), c.SuiteRunID()))
+			return nil
+		},
+		TeardownWithContext: func(c *handoff.RunContext) error {
+			teardownValue <- c.Value("tenant-id")
+			return nil
+		},
+		Tests: []model.TestFunc{func(t handoff.TB) {
+			if t.Value("tenant-id") != "tenant-setup-context-1" {
+				t.Fatalf("expected the tenant created by the setup, got %v", t.Value("tenant-id"))
+			}
+		}},
+	}
+
+	i := handoffInstance([]handoff.TestSuite{suite}, []string{"handoff-test", "-p", "0", "-d", ""}, handoff.WithHook(hook))
+	defer i.h.Shutdown()
+
+	tsr := i.createNewTestSuiteRun(t, "setup-context")
+	tsr = i.waitForTestSuiteRunWithResult(t, defaultTimeout, "setup-context", tsr.ID, model.ResultPassed)
+
+	assert.Equal(t, "tenant-setup-context-1", tsr.Context["tenant-id"], "expected the values of the setup to be persisted")
+	assert.Equal(t, "tenant-setup-context-1", <-teardownValue, "expected the teardown to receive the values of the setup")
+	assert.Equal(t, "tenant-setup-context-1", (<-hook.finished)["tenant-id"], "expected hooks to receive the values of the setup")
+
+	suite.Setup = func() error { return nil }
+
+	h := handoff.New(handoff.WithTestSuite(suite))
+	err := h.Run([]string{"handoff-test", "-d", "", "--headless"})
+	assert.Error(t, err, "expected a suite with both setup functions to be rejected")
+}
+
+func TestScheduledRunValidation(t *testing.T) {
+	t.Parallel()
+
+	suite := handoff.WithTestSuite(handoff.TestSuite{
+		Name:  "success",
+		Tests: []model.TestFunc{Success},
+	})
+
+	tests := []struct {
+		name string
+		sr   handoff.ScheduledRun
+	}{
+		{"unknown test suite", handoff.ScheduledRun{Name: "sr", TestSuiteName: "unknown", Schedule: "@every 1h"}},
+		{"invalid cron expression", handoff.ScheduledRun{Name: "sr", TestSuiteName: "success", Schedule: "every hour"}},
+		{"filter without matches", handoff.ScheduledRun{Name: "sr", TestSuiteName: "success", Schedule: "@every 1h", TestFilter: regexp.MustCompile("Fail")}},
+		{"missing name", handoff.ScheduledRun{TestSuiteName: "success", Schedule: "@every 1h"}},
+		{"invalid labels", handoff.ScheduledRun{Name: "sr", TestSuiteName: "success", Schedule: "@every 1h", Labels: "smoke &&"}},
+		{"labels without matches", handoff.ScheduledRun{Name: "sr", TestSuiteName: "success", Schedule: "@every 1h", Labels: "smoke"}},
+	}
+
+	for _, tt := range tests {
+		h := handoff.New(suite, handoff.WithScheduledRun(tt.sr))
+
+		err := h.Run([]string{})
+		assert.Errorf(t, err, "Passing in a scheduled run with %s should fail", tt.name)
+	}
+
+	sr := handoff.ScheduledRun{Name: "sr", TestSuiteName: "success", Schedule: "@every 1h"}
+	h := handoff.New(suite, handoff.WithScheduledRun(sr), handoff.WithScheduledRun(sr))
+
+	err := h.Run([]string{})
+	assert.Error(t, err, "Passing in multiple scheduled runs with the same name should fail")
+}
+
 func TestShutdownSucceeds(t *testing.T) {
 	t.Parallel()
 
@@ -162,8 +971,6 @@ func TestShutdownSucceeds(t *testing.T) {
 }
 
 func TestScheduledRunWithTestFilter(t *testing.T) {
-	t.Skip("we need to actally create a scheduled run to make this work")
-
 	t.Parallel()
 
 	suiteName := "success"
@@ -174,12 +981,18 @@ func TestScheduledRunWithTestFilter(t *testing.T) {
 		Tests: []model.TestFunc{Success, LogAttempt},
 	}}
 
-	i := handoffInstance(suites, []string{"handoff-test", "-p", "0", "-d", ""})
-
-	// make sure the scheduled run has started
-	time.Sleep(2 * time.Second)
+	i := handoffInstance(suites, []string{"handoff-test", "-p", "0", "-d", ""},
+		handoff.WithScheduledRun(handoff.ScheduledRun{
+			Name:          "filtered",
+			TestSuiteName: suiteName,
+			Schedule:      "@every 1s",
+			TestFilter:    regexp.MustCompile(filteredTestName),
+			MaxRuns:       1,
+		}),
+	)
 
 	tsr := i.waitForTestSuiteRunWithResult(t, defaultTimeout, suiteName, 1, model.ResultPassed)
+	assert.Equal(t, "filtered", tsr.ScheduleName)
 
 	tr := latestTestAttempt(t, tsr, filteredTestName)
 	assert.Equal(t, model.ResultPassed, tr.Result)
@@ -191,6 +1004,325 @@ func TestScheduledRunWithTestFilter(t *testing.T) {
 	assert.NoError(t, err, "service shutdown should succeed")
 }
 
+func TestRunsExceedingMaxRunningAreQueued(t *testing.T) {
+	t.Parallel()
+
+	suiteName := "queued"
+
+	suites := []handoff.TestSuite{{
+		Name:  suiteName,
+		Tests: []model.TestFunc{Sleep(300 * time.Millisecond)},
+	}}
+
+	i := handoffInstance(suites, []string{"handoff-test", "-p", "0", "-d", "", "--max-running", "1"})
+
+	first := i.createNewTestSuiteRun(t, suiteName)
+	assert.Equal(t, 0, first.QueuePosition, "expected first run to be started right away")
+
+	second := i.createNewTestSuiteRun(t, suiteName)
+	assert.Equal(t, 1, second.QueuePosition, "expected second run to be queued")
+
+	first = i.waitForTestSuiteRunWithResult(t, defaultTimeout, suiteName, first.ID, model.ResultPassed)
+	second = i.waitForTestSuiteRunWithResult(t, defaultTimeout, suiteName, second.ID, model.ResultPassed)
+	assert.False(t, second.Start.Before(first.End), "expected queued run to start after the first one finished")
+
+	err := i.h.Shutdown()
+	assert.NoError(t, err, "service shutdown should succeed")
+}
+
+func TestManageSchedules(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	scheduleName := "manage-schedules"
+
+	err := te.client.CreateSchedule(ctx, scheduleName, "succeed", "0 0 0 1 1 *", nil, 0)
+	assert.NoError(t, err, "creating schedule should succeed")
+
+	err = te.client.CreateSchedule(ctx, scheduleName, "succeed", "0 0 0 1 1 *", nil, 0)
+	var reqError client.RequestError
+	assert.ErrorAs(t, err, &reqError, "expected error of type RequestError")
+	assert.Equal(t, http.StatusConflict, reqError.ResponseCode, "expected duplicate schedule to conflict")
+
+	sr, err := te.client.GetSchedule(ctx, scheduleName)
+	assert.NoError(t, err, "get schedule should succeed")
+	assert.False(t, sr.Next.IsZero(), "expected schedule to have a next fire time")
+
+	err = te.client.UpdateSchedule(ctx, scheduleName, "0 0 0 1 2 *", regexp.MustCompile("Success"), 0)
+	assert.NoError(t, err, "updating schedule should succeed")
+
+	sr, err = te.client.GetSchedule(ctx, scheduleName)
+	assert.NoError(t, err, "get schedule should succeed")
+	assert.Equal(t, "0 0 0 1 2 *", sr.Schedule)
+	assert.Equal(t, time.February, sr.Next.Month(), "expected next fire time to match the updated schedule")
+
+	err = te.client.PauseSchedule(ctx, scheduleName)
+	assert.NoError(t, err, "pausing schedule should succeed")
+
+	sr, err = te.client.GetSchedule(ctx, scheduleName)
+	assert.NoError(t, err, "get schedule should succeed")
+	assert.True(t, sr.Paused, "expected schedule to be paused")
+	assert.True(t, sr.Next.IsZero(), "expected paused schedule to have no next fire time")
+
+	err = te.client.ResumeSchedule(ctx, scheduleName)
+	assert.NoError(t, err, "resuming schedule should succeed")
+
+	err = te.client.DeleteSchedule(ctx, scheduleName)
+	assert.NoError(t, err, "deleting schedule should succeed")
+
+	_, err = te.client.GetSchedule(ctx, scheduleName)
+	assert.ErrorAs(t, err, &reqError, "expected error of type RequestError")
+	assert.Equal(t, http.StatusNotFound, reqError.ResponseCode, "expected deleted schedule to be gone")
+}
+
+func TestScheduleStopsAfterMaxRuns(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	// the schedule gets its own instance so that its runs do not show up in other tests.
+	i := handoffInstance([]handoff.TestSuite{{
+		Name:  "succeed",
+		Tests: []model.TestFunc{Success},
+	}}, []string{"handoff-test", "-p", "0", "-d", ""})
+	defer i.h.Shutdown()
+
+	scheduleName := "max-runs"
+
+	err := i.client.CreateSchedule(ctx, scheduleName, "succeed", "@every 1s", nil, 2)
+	assert.NoError(t, err, "creating schedule should succeed")
+
+	var sr client.Schedule
+
+	assert.Eventually(t, func() bool {
+		sr, err = i.client.GetSchedule(ctx, scheduleName)
+
+		return err == nil && sr.RunCount == 2
+	}, 5*time.Second, 100*time.Millisecond, "expected schedule to run twice")
+
+	assert.True(t, sr.Next.IsZero(), "expected schedule to be disabled after reaching max runs")
+
+	time.Sleep(1500 * time.Millisecond)
+
+	runs, err := i.client.GetScheduleRuns(ctx, scheduleName)
+	assert.NoError(t, err, "get schedule runs should succeed")
+	assert.Len(t, runs, 2, "expected exactly max runs test suite runs")
+
+	for _, run := range runs {
+		assert.Equal(t, scheduleName, run.ScheduleName)
+	}
+}
+
+func TestPersistedSchedulesAreRestoredOnStartup(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	suites := []handoff.TestSuite{{
+		Name:  "success",
+		Tests: []model.TestFunc{Success},
+	}}
+	args := []string{"handoff-test", "-p", "0", "-d", t.TempDir()}
+
+	i := handoffInstance(suites, args)
+
+	err := i.client.CreateSchedule(ctx, "persisted", "success", "@every 1h", nil, 0)
+	assert.NoError(t, err, "creating schedule should succeed")
+
+	assert.NoError(t, i.h.Shutdown(), "service shutdown should succeed")
+
+	i = handoffInstance(suites, args)
+
+	sr, err := i.client.GetSchedule(ctx, "persisted")
+	assert.NoError(t, err, "expected persisted schedule to be restored")
+	assert.False(t, sr.Next.IsZero(), "expected restored schedule to be registered")
+
+	assert.NoError(t, i.h.Shutdown(), "service shutdown should succeed")
+}
+
+func TestListTestSuiteRunsIsPaginatedAndFiltered(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	suites := []handoff.TestSuite{{
+		Name:  "paginated",
+		Tests: []model.TestFunc{Success},
+	}, {
+		Name:  "paginated-failing",
+		Tests: []model.TestFunc{Fail},
+	}}
+
+	i := handoffInstance(suites, []string{"handoff-test", "-p", "0", "-d", ""})
+	defer i.h.Shutdown()
+
+	for range 3 {
+		tsr := i.createNewTestSuiteRun(t, "paginated")
+		i.waitForTestSuiteRunWithResult(t, defaultTimeout, "paginated", tsr.ID, model.ResultPassed)
+	}
+
+	tsr := i.createNewTestSuiteRun(t, "paginated-failing")
+	i.waitForTestSuiteRunWithResult(t, defaultTimeout, "paginated-failing", tsr.ID, model.ResultFailed)
+
+	runs, next, err := i.client.ListTestSuiteRuns(ctx, "paginated", client.TestSuiteRunFilter{}, 0, 2)
+	assert.NoError(t, err, "listing test suite runs should succeed")
+	assert.Len(t, runs, 2)
+	assert.Equal(t, 3, runs[0].ID, "expected the newest run first")
+	assert.Equal(t, 2, next)
+
+	runs, next, err = i.client.ListTestSuiteRuns(ctx, "paginated", client.TestSuiteRunFilter{}, next, 2)
+	assert.NoError(t, err, "listing the next page should succeed")
+	assert.Len(t, runs, 1)
+	assert.Equal(t, 1, runs[0].ID)
+	assert.Equal(t, 0, next, "expected no further page")
+
+	runs, _, err = i.client.ListTestSuiteRuns(ctx, "paginated", client.TestSuiteRunFilter{Result: model.ResultFailed}, 0, 0)
+	assert.NoError(t, err, "filtering test suite runs should succeed")
+	assert.Empty(t, runs, "expected runs of suites with the same prefix not to be listed")
+
+	_, _, err = i.client.ListTestSuiteRuns(ctx, "paginated", client.TestSuiteRunFilter{Result: "unknown"}, 0, 0)
+
+	var reqError client.RequestError
+
+	assert.ErrorAs(t, err, &reqError, "expected error of type RequestError")
+	assert.Equal(t, http.StatusBadRequest, reqError.ResponseCode)
+}
+
+func TestSearchTestSuiteRunsAcrossSuites(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	suites := []handoff.TestSuite{{
+		Name:      "search-api",
+		Namespace: "team-search",
+		Tests:     []model.TestFunc{SetCorrelationID},
+	}, {
+		Name:  "search-web",
+		Tests: []model.TestFunc{Success},
+	}}
+
+	i := handoffInstance(suites, []string{"handoff-test", "-p", "0", "-d", ""})
+	defer i.h.Shutdown()
+
+	for _, suiteName := range []string{"search-api", "search-web", "search-api"} {
+		res, err := http.Post(fmt.Sprintf("http://localhost:%d/suites/%s/runs?ref=%s", i.h.ServerPort(), suiteName, url.QueryEscape("PR #1234")), "", nil)
+		assert.NoError(t, err, "starting a test suite run should succeed")
+
+		var tsr client.TestSuiteRun
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&tsr))
+		res.Body.Close()
+
+		i.waitForTestSuiteRunWithResult(t, defaultTimeout, suiteName, tsr.ID, model.ResultPassed)
+	}
+
+	i.createNewTestSuiteRun(t, "search-web")
+
+	runs, next, err := i.client.SearchTestSuiteRuns(ctx, client.TestSuiteRunFilter{Reference: "PR #1234"}, "", 2)
+	assert.NoError(t, err, "searching test suite runs should succeed")
+	assert.Len(t, runs, 2)
+	assert.Equal(t, "search-api", runs[0].SuiteName, "expected the newest run first")
+	assert.Equal(t, 2, runs[0].ID)
+	assert.NotEmpty(t, next, "expected a next page")
+
+	runs, next, err = i.client.SearchTestSuiteRuns(ctx, client.TestSuiteRunFilter{Reference: "PR #1234"}, next, 2)
+	assert.NoError(t, err, "searching the next page should succeed")
+	assert.Len(t, runs, 1)
+	assert.Equal(t, "search-api", runs[0].SuiteName)
+	assert.Equal(t, 1, runs[0].ID)
+	assert.Empty(t, next, "expected no further page")
+
+	runs, _, err = i.client.SearchTestSuiteRuns(ctx, client.TestSuiteRunFilter{
+		Namespace: "team-search",
+		Context:   map[string]string{"correlation-id": "checkout-42"},
+	}, "", 0)
+	assert.NoError(t, err, "searching by test context should succeed")
+	assert.Len(t, runs, 2, "expected the runs of the namespace that set the correlation id")
+
+	runs, _, err = i.client.SearchTestSuiteRuns(ctx, client.TestSuiteRunFilter{TestName: "Success", Reference: "PR #1234"}, "", 0)
+	assert.NoError(t, err, "searching by test name should succeed")
+	assert.Len(t, runs, 1)
+	assert.Equal(t, "search-web", runs[0].SuiteName)
+
+	_, _, err = i.client.SearchTestSuiteRuns(ctx, client.TestSuiteRunFilter{}, "not-a-cursor", 0)
+
+	var reqError client.RequestError
+
+	assert.ErrorAs(t, err, &reqError, "expected error of type RequestError")
+	assert.Equal(t, http.StatusBadRequest, reqError.ResponseCode)
+}
+
+func TestNamespaceRunsAllSuitesOfTheNamespace(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	suites := []handoff.TestSuite{{
+		Name:      "team-a-api",
+		Namespace: "team-a",
+		Tests:     []model.TestFunc{Success},
+	}, {
+		Name:      "team-a-web",
+		Namespace: "team-a",
+		Tests:     []model.TestFunc{Fail},
+	}, {
+		Name:      "team-b-api",
+		Namespace: "team-b",
+		Tests:     []model.TestFunc{Success},
+	}, {
+		Name:  "no-team",
+		Tests: []model.TestFunc{Success},
+	}}
+
+	i := handoffInstance(suites, []string{"handoff-test", "-p", "0", "-d", ""})
+	defer i.h.Shutdown()
+
+	namespaces, err := i.client.ListNamespaces(ctx)
+	assert.NoError(t, err, "listing namespaces should succeed")
+	assert.Len(t, namespaces, 2, "expected suites without a namespace not to be listed")
+	assert.Equal(t, "team-a", namespaces[0].Name)
+	assert.Len(t, namespaces[0].Suites, 2)
+	assert.Nil(t, namespaces[0].Suites[0].LatestRun, "expected no latest run before the suite is run")
+
+	runs, err := i.client.CreateNamespaceRuns(ctx, "team-a", nil)
+	assert.NoError(t, err, "starting the runs of a namespace should succeed")
+	assert.Len(t, runs, 2)
+
+	i.waitForTestSuiteRunWithResult(t, defaultTimeout, "team-a-api", runs[0].ID, model.ResultPassed)
+	i.waitForTestSuiteRunWithResult(t, defaultTimeout, "team-a-web", runs[1].ID, model.ResultFailed)
+
+	namespaceSuites, err := i.client.ListNamespaceSuites(ctx, "team-a")
+	assert.NoError(t, err, "listing the suites of a namespace should succeed")
+	assert.Len(t, namespaceSuites, 2)
+	assert.Equal(t, "team-a-api", namespaceSuites[0].Name)
+	assert.Equal(t, model.ResultPassed, namespaceSuites[0].LatestRun.Result)
+	assert.Equal(t, model.ResultFailed, namespaceSuites[1].LatestRun.Result)
+
+	teamB, err := i.client.ListNamespaceSuites(ctx, "team-b")
+	assert.NoError(t, err)
+	assert.Nil(t, teamB[0].LatestRun, "expected suites of other namespaces not to be run")
+
+	filtered, err := i.client.CreateNamespaceRuns(ctx, "team-a", regexp.MustCompile("^Success$"))
+	assert.NoError(t, err, "starting the filtered runs of a namespace should succeed")
+	assert.Len(t, filtered, 1, "expected suites without matching tests to be skipped")
+	assert.Equal(t, "team-a-api", filtered[0].SuiteName)
+
+	i.waitForTestSuiteRunWithResult(t, defaultTimeout, "team-a-api", filtered[0].ID, model.ResultPassed)
+
+	_, err = i.client.CreateNamespaceRuns(ctx, "unknown", nil)
+
+	var reqError client.RequestError
+
+	assert.ErrorAs(t, err, &reqError, "expected error of type RequestError")
+	assert.Equal(t, http.StatusNotFound, reqError.ResponseCode)
+
+	_, err = i.client.CreateNamespaceRuns(ctx, "team-a", regexp.MustCompile("^Unknown$"))
+
+	assert.ErrorAs(t, err, &reqError, "expected error of type RequestError")
+	assert.Equal(t, http.StatusBadRequest, reqError.ResponseCode, "expected a filter without matching tests to be rejected")
+}
+
 func TestSuiteRunWithUnknownSuiteShouldFailSuiteNotFoundReturns404(t *testing.T) {
 	t.Parallel()
 
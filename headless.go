@@ -0,0 +1,244 @@
+package handoff
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/raphi011/handoff/internal/junit"
+	"github.com/raphi011/handoff/internal/model"
+)
+
+// errTestSuiteRunsFailed is returned by `Server.Run()` in headless mode if at least one
+// of the test suite runs did not pass.
+var errTestSuiteRunsFailed = errors.New("test suite runs failed")
+
+// reportWriters contains the supported report formats keyed by file extension.
+var reportWriters = map[string]func(w io.Writer, runs []model.TestSuiteRun) error{
+	".json": writeJSONReport,
+	".xml":  junit.Write,
+}
+
+// headless returns true if the server runs the selected test suites once and exits
+// instead of starting the web server.
+func (c config) headless() bool {
+	return c.Headless || len(c.Run) > 0
+}
+
+// runHeadless runs the selected test suites once, prints a summary, writes the reports
+// and shuts down the server. An error is returned if any of the test suite runs did not pass.
+func (s *Server) runHeadless() (err error) {
+	defer func() {
+		s.gracefulShutdown()
+
+		err = errors.Join(err, <-s.hasShutdown)
+	}()
+
+	suites, filter, labels, err := s.headlessTestSuites()
+	if err != nil {
+		return err
+	}
+
+	for _, report := range s.config.Reports {
+		if _, ok := reportWriters[filepath.Ext(report)]; !ok {
+			return fmt.Errorf("unsupported report format %q", report)
+		}
+	}
+
+	close(s.started)
+
+	finished := make(chan any)
+	defer close(finished)
+
+	go func() {
+		select {
+		case <-s.shutdown:
+			s.queue.close()
+			s.cancelRunCtx(errServerShutdown)
+		case <-finished:
+		}
+	}()
+
+	runs := []model.TestSuiteRun{}
+
+	for _, ts := range suites {
+		tsr, err := s.startNewTestSuiteRun(ts, model.RunParams{
+			InitiatedBy: "headless",
+			TestFilter:  filter,
+			Labels:      labels,
+		})
+		if err != nil {
+			return fmt.Errorf("starting test suite run %s: %w", ts.Name, err)
+		}
+
+		runs = append(runs, tsr)
+	}
+
+	// nothing else can start new runs in headless mode, queued runs are started
+	// before the running ones are done so we can wait for all of them at once.
+	s.runningTestSuites.Wait()
+
+	ctx := context.Background()
+
+	for i, tsr := range runs {
+		runs[i], err = s.storage.LoadTestSuiteRun(ctx, tsr.SuiteName, tsr.ID)
+		if err != nil {
+			return fmt.Errorf("loading test suite run %s: %w", tsr.SuiteName, err)
+		}
+	}
+
+	printSummary(os.Stdout, runs)
+
+	var reportErr error
+
+	for _, report := range s.config.Reports {
+		if err := writeReport(report, runs); err != nil {
+			reportErr = errors.Join(reportErr, fmt.Errorf("writing report %s: %w", report, err))
+		}
+	}
+
+	if reportErr != nil {
+		return reportErr
+	}
+
+	failed := 0
+	for _, tsr := range runs {
+		if tsr.Result != model.ResultPassed {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%w: %d of %d did not pass", errTestSuiteRunsFailed, failed, len(runs))
+	}
+
+	return nil
+}
+
+// headlessTestSuites returns the test suites selected by name or namespace via `--run`
+// sorted by name, or all test suites if none are selected. Test suites without any tests
+// matching the `--filter` and `--labels` are left out.
+func (s *Server) headlessTestSuites() ([]model.TestSuite, *regexp.Regexp, *model.LabelSelector, error) {
+	var (
+		filter *regexp.Regexp
+		labels *model.LabelSelector
+		err    error
+	)
+
+	if s.config.RunFilter != "" {
+		filter, err = regexp.Compile(s.config.RunFilter)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("invalid test filter: %w", err)
+		}
+	}
+
+	if s.config.RunLabels != "" {
+		labels, err = model.ParseLabelSelector(s.config.RunLabels)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("invalid labels: %w", err)
+		}
+	}
+
+	for _, selector := range s.config.Run {
+		found := false
+
+		for _, ts := range s.readOnlyTestSuites {
+			if ts.Name == selector || ts.Namespace == selector {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return nil, nil, nil, fmt.Errorf("no test suite or namespace with name %s", selector)
+		}
+	}
+
+	suites := []model.TestSuite{}
+
+	for _, ts := range s.readOnlyTestSuites {
+		if len(s.config.Run) > 0 &&
+			!slices.Contains(s.config.Run, ts.Name) &&
+			!slices.Contains(s.config.Run, ts.Namespace) {
+			continue
+		}
+
+		if len(ts.FilterTests(filter, labels)) == 0 {
+			continue
+		}
+
+		suites = append(suites, ts)
+	}
+
+	if len(suites) == 0 {
+		return nil, nil, nil, errors.New("no test suites match the selection")
+	}
+
+	sort.Slice(suites, func(i, j int) bool {
+		return suites[i].Name < suites[j].Name
+	})
+
+	return suites, filter, labels, nil
+}
+
+// printSummary writes the results of the test suite runs and their failed tests.
+func printSummary(w io.Writer, runs []model.TestSuiteRun) {
+	b := strings.Builder{}
+
+	for _, tsr := range runs {
+		b.WriteString(fmt.Sprintf("%-9s %s (run %d, %dms)\n", strings.ToUpper(string(tsr.Result)), tsr.SuiteName, tsr.ID, tsr.DurationInMS))
+
+		for _, tr := range tsr.LatestTestAttempts() {
+			if tr.Result == model.ResultPassed || tr.Result == model.ResultSkipped {
+				continue
+			}
+
+			b.WriteString(fmt.Sprintf("\t--- %s: %s (attempt %d)\n", strings.ToUpper(string(tr.Result)), tr.Name, tr.Attempt))
+			writeIndentedLogs(&b, tr.Logs)
+		}
+
+		if tsr.TeardownResult == model.ResultFailed {
+			b.WriteString(fmt.Sprintf("\t--- %s: teardown\n", strings.ToUpper(string(tsr.TeardownResult))))
+			writeIndentedLogs(&b, tsr.TeardownLogs)
+		}
+	}
+
+	fmt.Fprint(w, b.String())
+}
+
+func writeIndentedLogs(b *strings.Builder, logs string) {
+	for _, line := range strings.Split(strings.TrimSpace(logs), "\n") {
+		if line != "" {
+			b.WriteString("\t\t" + line + "\n")
+		}
+	}
+}
+
+func writeReport(path string, runs []model.TestSuiteRun) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := reportWriters[filepath.Ext(path)](f, runs); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+func writeJSONReport(w io.Writer, runs []model.TestSuiteRun) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(runs)
+}
@@ -6,10 +6,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	_ "net/http/pprof"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -18,6 +20,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/raphi011/handoff/internal/html"
 	"github.com/raphi011/handoff/internal/html/assets"
+	"github.com/raphi011/handoff/internal/junit"
 	"github.com/raphi011/handoff/internal/model"
 	"github.com/yuin/goldmark"
 )
@@ -31,6 +34,14 @@ func (e malformedRequestError) Error() string {
 	return "malformed request param: " + e.param + " reason: " + e.reason
 }
 
+type conflictError struct {
+	reason string
+}
+
+func (e conflictError) Error() string {
+	return "conflict: " + e.reason
+}
+
 func (s *Server) runHTTP() error {
 	router := httprouter.New()
 
@@ -44,14 +55,33 @@ func (s *Server) runHTTP() error {
 	router.GET("/ready", s.getReady)
 
 	router.POST("/suites/:suite-name/runs", s.startTestSuite)
+	router.POST("/suites/:suite-name/imports", s.importTestSuiteRunResults)
 	router.GET("/suites", s.getTestSuitesWithRuns)
 	router.GET("/suites/:suite-name/runs", s.getTestSuiteRuns)
 	router.GET("/suites/:suite-name/runs/:run-id", s.getTestSuiteRun)
+	router.POST("/suites/:suite-name/runs/:run-id/cancel", s.cancelRun)
+	router.POST("/suites/:suite-name/runs/:run-id/rerun", s.rerunRun)
+	router.GET("/suites/:suite-name/runs/:run-id/events", s.streamRunEvents)
 	router.GET("/suites/:suite-name/runs/:run-id/test/:test-name", s.getTestRunResult)
+	router.GET("/suites/:suite-name/durations", s.getDurationStats)
+
+	router.GET("/runs", s.searchTestSuiteRuns)
+
+	router.GET("/namespaces", s.getNamespaces)
+	router.GET("/namespaces/:namespace/suites", s.getNamespaceSuites)
+	router.POST("/namespaces/:namespace/runs", s.startNamespace)
+
+	router.GET("/queue", s.getQueuedTestSuiteRuns)
+	router.GET("/flaky", s.getFlakyTests)
 
 	router.GET("/schedules", s.getSchedules)
+	router.GET("/schedules/:schedule-name", s.getSchedule)
+	router.GET("/schedules/:schedule-name/runs", s.getScheduleRuns)
 	router.POST("/schedules/:schedule-name", s.createSchedule)
+	router.PUT("/schedules/:schedule-name", s.updateSchedule)
 	router.DELETE("/schedules/:schedule-name", s.deleteSchedule)
+	router.POST("/schedules/:schedule-name/pause", s.pauseSchedule)
+	router.POST("/schedules/:schedule-name/resume", s.resumeSchedule)
 
 	router.ServeFiles("/assets/*filepath", http.FS(assets.Assets))
 
@@ -60,7 +90,7 @@ func (s *Server) runHTTP() error {
 		// TODO: set reasonable timeouts
 
 		// needed for debug/pprof/profile endpoint
-		WriteTimeout: 31 * time.Second,
+		WriteTimeout: s.config.WriteTimeout,
 	}
 
 	l, err := net.Listen("tcp", fmt.Sprintf("%s:%d", s.config.HostIP, s.config.Port))
@@ -112,7 +142,19 @@ func (s *Server) startTestSuite(w http.ResponseWriter, r *http.Request, p httpro
 	initiatedBy := r.URL.Query().Get("initiatedby")
 	idempotencyKey := r.Header.Get("Idempotency-Key")
 
-	filter, err := filterParam(ts, r)
+	filter, labels, err := testSelectionParams(ts, r)
+	if err != nil {
+		s.httpError(w, err)
+		return
+	}
+
+	timeout, err := durationParam(r, "timeout")
+	if err != nil {
+		s.httpError(w, err)
+		return
+	}
+
+	repeat, err := repeatParam(r)
 	if err != nil {
 		s.httpError(w, err)
 		return
@@ -121,22 +163,302 @@ func (s *Server) startTestSuite(w http.ResponseWriter, r *http.Request, p httpro
 	tsr, err := s.startNewTestSuiteRun(ts, model.RunParams{
 		InitiatedBy:    initiatedBy,
 		TestFilter:     filter,
+		Labels:         labels,
 		Reference:      reference,
 		IdempotencyKey: idempotencyKey,
+		Timeout:        timeout,
+		Repeat:         repeat,
+	})
+	if err != nil {
+		s.httpError(w, err)
+		return
+	}
+
+	s.writeResponse(w, r, http.StatusCreated, tsr)
+}
+
+// startNamespace starts a run of every test suite of a namespace.
+func (s *Server) startNamespace(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	timeout, err := durationParam(r, "timeout")
+	if err != nil {
+		s.httpError(w, err)
+		return
+	}
+
+	filter, err := filterParam(r)
+	if err != nil {
+		s.httpError(w, err)
+		return
+	}
+
+	labels, err := labelsParam(r)
+	if err != nil {
+		s.httpError(w, err)
+		return
+	}
+
+	namespace := p.ByName("namespace")
+
+	runs, err := s.startNamespaceRuns(namespace, model.RunParams{
+		TestFilter:     filter,
+		Labels:         labels,
+		InitiatedBy:    r.URL.Query().Get("initiatedby"),
+		Reference:      r.URL.Query().Get("ref"),
+		IdempotencyKey: r.Header.Get("Idempotency-Key"),
+		Timeout:        timeout,
+	})
+	if err != nil && len(runs) > 0 {
+		// some suites were started before the error. they are returned so that the caller
+		// can follow them, a retry with the same idempotency key starts only the others.
+		s.log.Warn("starting the runs of a namespace failed", "namespace", namespace,
+			"started", len(runs), "error", err)
+		s.writeResponse(w, r, http.StatusInternalServerError, runs)
+		return
+	} else if err != nil {
+		s.httpError(w, err)
+		return
+	}
+
+	s.writeResponse(w, r, http.StatusCreated, runs)
+}
+
+func (s *Server) getNamespaces(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	namespaces := []model.Namespace{}
+
+	for _, name := range s.listNamespaces() {
+		ns, err := s.loadNamespace(r.Context(), name)
+		if err != nil {
+			s.httpError(w, err)
+			return
+		}
+
+		namespaces = append(namespaces, ns)
+	}
+
+	s.writeResponse(w, r, http.StatusOK, namespaces)
+}
+
+func (s *Server) getNamespaceSuites(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	ns, err := s.loadNamespace(r.Context(), p.ByName("namespace"))
+	if err != nil {
+		s.httpError(w, err)
+		return
+	}
+
+	s.writeResponse(w, r, http.StatusOK, ns.Suites)
+}
+
+func (s *Server) importTestSuiteRunResults(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = importFormat(r.Header.Get("Content-Type"))
+	}
+
+	parse, ok := importFormats[format]
+	if !ok {
+		s.httpError(w, malformedRequestError{param: "format", reason: "must be either junit or gotest"})
+		return
+	}
+
+	tsr, err := parse(http.MaxBytesReader(w, r.Body, maxImportSize))
+
+	var tooLarge *http.MaxBytesError
+
+	if errors.As(err, &tooLarge) {
+		s.httpError(w, err)
+		return
+	} else if err != nil {
+		s.httpError(w, malformedRequestError{param: "body", reason: err.Error()})
+		return
+	}
+
+	initiatedBy := r.URL.Query().Get("initiatedby")
+	if initiatedBy == "" {
+		initiatedBy = "import"
+	}
+
+	tsr, err = s.importTestSuiteRun(r.Context(), p.ByName("suite-name"), r.URL.Query().Get("namespace"), tsr, model.RunParams{
+		InitiatedBy: initiatedBy,
+		Reference:   r.URL.Query().Get("ref"),
+	})
+	if err != nil {
+		s.httpError(w, err)
+		return
+	}
+
+	s.writeResponse(w, r, http.StatusCreated, tsr)
+}
+
+func (s *Server) cancelRun(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	suiteName := p.ByName("suite-name")
+	runID, err := strconv.Atoi(p.ByName("run-id"))
+	if err != nil {
+		s.httpError(w, malformedRequestError{param: "run-id", reason: "must be an integer"})
+		return
+	}
+
+	if err := s.cancelTestSuiteRun(r.Context(), suiteName, runID); err != nil {
+		s.httpError(w, err)
+		return
+	}
+
+	if headerAcceptsType(r.Header, "text/html") {
+		http.Redirect(w, r, fmt.Sprintf("/suites/%s/runs/%d", suiteName, runID), http.StatusSeeOther)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// rerunRun starts a new run with the failed tests of a finished run.
+func (s *Server) rerunRun(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	runID, err := strconv.Atoi(p.ByName("run-id"))
+	if err != nil {
+		s.httpError(w, malformedRequestError{param: "run-id", reason: "must be an integer"})
+		return
+	}
+
+	tsr, err := s.rerunFailedTests(r.Context(), p.ByName("suite-name"), runID, model.RunParams{
+		InitiatedBy:    r.URL.Query().Get("initiatedby"),
+		Reference:      r.URL.Query().Get("ref"),
+		IdempotencyKey: r.Header.Get("Idempotency-Key"),
 	})
 	if err != nil {
 		s.httpError(w, err)
+		return
+	}
+
+	if headerAcceptsType(r.Header, "text/html") {
+		http.Redirect(w, r, fmt.Sprintf("/suites/%s/runs/%d", tsr.SuiteName, tsr.ID), http.StatusSeeOther)
+		return
 	}
 
 	s.writeResponse(w, r, http.StatusCreated, tsr)
 }
 
+// streamRunEvents streams the progress of a test suite run as server-sent events until
+// the run is finished. For runs that are already finished only the `run-finished` event
+// is sent.
+func (s *Server) streamRunEvents(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		s.httpError(w, errors.New("streaming is not supported"))
+		return
+	}
+
+	runID, err := strconv.Atoi(p.ByName("run-id"))
+	if err != nil {
+		s.httpError(w, malformedRequestError{param: "run-id", reason: "must be an integer"})
+		return
+	}
+
+	// subscribe before loading the run to make sure that no events are missed
+	events, unsubscribe := s.events.subscribe(p.ByName("suite-name"), runID)
+	defer unsubscribe()
+
+	tsr, err := s.loadTestSuiteRun(r.Context(), p)
+	if err != nil {
+		s.httpError(w, err)
+		return
+	}
+
+	// the stream lasts as long as the run, which is usually longer than the
+	// write timeout of the server.
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		s.log.Warn("clearing the write deadline of the run event stream failed", "error", err)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	if tsr.Result != model.ResultPending {
+		writeRunEvent(w, model.RunEvent{
+			Type:      model.RunEventRunFinished,
+			SuiteName: tsr.SuiteName,
+			RunID:     tsr.ID,
+			Time:      tsr.End,
+			Result:    tsr.Result,
+		})
+		flusher.Flush()
+
+		return
+	}
+
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(runEventKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.shutdown:
+			return
+		case <-keepAlive.C:
+			// comments are ignored by clients, they keep proxies from closing idle streams.
+			if _, err := io.WriteString(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case e, ok := <-events:
+			if !ok {
+				// the client did not keep up with the events
+				return
+			}
+
+			if err := writeRunEvent(w, e); err != nil {
+				return
+			}
+			flusher.Flush()
+
+			if e.Type == model.RunEventRunFinished {
+				return
+			}
+		}
+	}
+}
+
+// runEventKeepAliveInterval is the interval in which a comment is sent to idle run
+// event streams.
+const runEventKeepAliveInterval = 15 * time.Second
+
+func writeRunEvent(w io.Writer, e model.RunEvent) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+
+	return err
+}
+
 func (s *Server) getSchedules(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
-	var schedules []model.ScheduledRun
+	s.writeResponse(w, r, http.StatusOK, s.listSchedules())
+}
+
+func (s *Server) getSchedule(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	sr, err := s.getScheduleByName(p.ByName("schedule-name"))
+	if err != nil {
+		s.httpError(w, err)
+		return
+	}
 
-	schedules = append(schedules, s.readOnlySchedules...)
+	s.writeResponse(w, r, http.StatusOK, sr)
+}
 
-	s.writeResponse(w, r, http.StatusOK, schedules)
+func (s *Server) getScheduleRuns(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	runs, err := s.loadScheduleRuns(r.Context(), p.ByName("schedule-name"))
+	if err != nil {
+		s.httpError(w, err)
+		return
+	}
+
+	if err := s.writeResponse(w, r, http.StatusOK, runs); err != nil {
+		s.log.Warn("writing get schedule runs response", "error", err)
+	}
 }
 
 func (s *Server) deleteSchedule(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
@@ -146,7 +468,7 @@ func (s *Server) deleteSchedule(w http.ResponseWriter, r *http.Request, p httpro
 		return
 	}
 
-	err := s.storage.DeleteScheduledRun(context.Background(), scheduleName)
+	err := s.removeSchedule(r.Context(), scheduleName)
 	if err != nil {
 		s.httpError(w, fmt.Errorf("failed to delete scheduled run: %w", err))
 		return
@@ -158,13 +480,19 @@ func (s *Server) deleteSchedule(w http.ResponseWriter, r *http.Request, p httpro
 func (s *Server) createSchedule(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 	scheduleName := p.ByName("schedule-name")
 
-	ts, err := s.loadTestSuite(r, p)
+	ts, ok := s.readOnlyTestSuites[r.URL.Query().Get("suite")]
+	if !ok {
+		s.httpError(w, model.NotFoundError{})
+		return
+	}
+
+	filter, labels, err := testSelectionParams(ts, r)
 	if err != nil {
 		s.httpError(w, err)
 		return
 	}
-	schedule := r.Header.Get("schedule")
-	filter, err := filterParam(ts, r)
+
+	maxRuns, err := intParam(r, "max-runs", 0)
 	if err != nil {
 		s.httpError(w, err)
 		return
@@ -173,11 +501,13 @@ func (s *Server) createSchedule(w http.ResponseWriter, r *http.Request, p httpro
 	sr := model.ScheduledRun{
 		Name:          scheduleName,
 		TestSuiteName: ts.Name,
-		Schedule:      schedule,
+		Schedule:      scheduleParam(r),
 		TestFilter:    filter,
+		Labels:        labels,
+		MaxRuns:       maxRuns,
 	}
 
-	if _, err := s.startSchedule(sr, true); err != nil {
+	if err := s.createPersistedSchedule(r.Context(), sr); err != nil {
 		s.httpError(w, err)
 		return
 	}
@@ -185,7 +515,90 @@ func (s *Server) createSchedule(w http.ResponseWriter, r *http.Request, p httpro
 	w.WriteHeader(http.StatusCreated)
 }
 
-func filterParam(ts model.TestSuite, r *http.Request) (*regexp.Regexp, error) {
+func (s *Server) updateSchedule(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	scheduleName := p.ByName("schedule-name")
+
+	sr, err := s.getScheduleByName(scheduleName)
+	if err != nil {
+		s.httpError(w, err)
+		return
+	}
+
+	filter, labels, err := testSelectionParams(s.readOnlyTestSuites[sr.TestSuiteName], r)
+	if err != nil {
+		s.httpError(w, err)
+		return
+	}
+
+	maxRuns, err := intParam(r, "max-runs", sr.MaxRuns)
+	if err != nil {
+		s.httpError(w, err)
+		return
+	}
+
+	if err := s.modifyScheduleDefinition(r.Context(), scheduleName, scheduleParam(r), filter, labels, maxRuns); err != nil {
+		s.httpError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) pauseSchedule(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	if err := s.setSchedulePaused(r.Context(), p.ByName("schedule-name"), true); err != nil {
+		s.httpError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) resumeSchedule(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	if err := s.setSchedulePaused(r.Context(), p.ByName("schedule-name"), false); err != nil {
+		s.httpError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// scheduleParam returns the cron expression of a schedule, it is passed in
+// via the `schedule` header or query param.
+func scheduleParam(r *http.Request) string {
+	if schedule := r.Header.Get("schedule"); schedule != "" {
+		return schedule
+	}
+
+	return r.URL.Query().Get("schedule")
+}
+
+// testSelectionParams returns the regex `filter` and the `labels` selector that select the
+// tests of a run, an error is returned if none of the tests of the suite match both.
+func testSelectionParams(ts model.TestSuite, r *http.Request) (*regexp.Regexp, *model.LabelSelector, error) {
+	filterRegex, err := filterParam(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	labels, err := labelsParam(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(ts.FilterTests(filterRegex, labels)) == 0 {
+		param := "filter"
+		if labels != nil {
+			param = "labels"
+		}
+
+		return nil, nil, malformedRequestError{param: param, reason: "no tests match the given filter"}
+	}
+
+	return filterRegex, labels, nil
+}
+
+// filterParam returns the regex of the `filter` param that selects tests by name.
+func filterParam(r *http.Request) (*regexp.Regexp, error) {
 	filter := r.URL.Query().Get("filter")
 	if filter == "" {
 		return nil, nil
@@ -196,11 +609,146 @@ func filterParam(ts model.TestSuite, r *http.Request) (*regexp.Regexp, error) {
 		return nil, malformedRequestError{param: "filter", reason: "invalid regex"}
 	}
 
-	if len(ts.FilterTests(filterRegex)) == 0 {
-		return nil, malformedRequestError{param: "filter", reason: "no tests match the given filter"}
+	return filterRegex, nil
+}
+
+// labelsParam returns the label selector of the `labels` param, e.g. `smoke && !slow`.
+func labelsParam(r *http.Request) (*model.LabelSelector, error) {
+	expr := r.URL.Query().Get("labels")
+	if expr == "" {
+		return nil, nil
 	}
 
-	return filterRegex, nil
+	labels, err := model.ParseLabelSelector(expr)
+	if err != nil {
+		return nil, malformedRequestError{param: "labels", reason: err.Error()}
+	}
+
+	return labels, nil
+}
+
+// intParam returns the integer value of a query param or `fallback` if it is not set.
+func intParam(r *http.Request, param string, fallback int) (int, error) {
+	value := r.URL.Query().Get(param)
+	if value == "" {
+		return fallback, nil
+	}
+
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, malformedRequestError{param: param, reason: "must be an integer"}
+	}
+
+	return i, nil
+}
+
+// defaultRunsPageSize and maxRunsPageSize limit the number of test suite runs
+// that are listed at once.
+const (
+	defaultRunsPageSize = 50
+	maxRunsPageSize     = 500
+)
+
+// runFilterParams returns the filter of a test suite run listing. Runs can be filtered
+// by `result`, `environment`, `initiated-by`, `reference`, `idempotency-key`, `flaky`,
+// the time they were scheduled at (`from` and `to` in RFC 3339 format), the name of a
+// test they contain (`test`) and the values of a test context (`context=key=value`, can
+// be repeated).
+func runFilterParams(r *http.Request) (model.TestSuiteRunFilter, error) {
+	query := r.URL.Query()
+
+	filter := model.TestSuiteRunFilter{
+		Result:         model.Result(query.Get("result")),
+		Environment:    query.Get("environment"),
+		InitiatedBy:    query.Get("initiated-by"),
+		Reference:      query.Get("reference"),
+		IdempotencyKey: query.Get("idempotency-key"),
+		TestName:       query.Get("test"),
+	}
+
+	switch filter.Result {
+	case "", model.ResultPending, model.ResultSkipped, model.ResultPassed, model.ResultFailed, model.ResultCancelled:
+	default:
+		return filter, malformedRequestError{param: "result", reason: "unknown result"}
+	}
+
+	for param, t := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		if value := query.Get(param); value != "" {
+			var err error
+			if *t, err = time.Parse(time.RFC3339, value); err != nil {
+				return filter, malformedRequestError{param: param, reason: "must be a RFC 3339 timestamp"}
+			}
+		}
+	}
+
+	if value := query.Get("flaky"); value != "" {
+		flaky, err := strconv.ParseBool(value)
+		if err != nil {
+			return filter, malformedRequestError{param: "flaky", reason: "must be a boolean"}
+		}
+
+		filter.Flaky = &flaky
+	}
+
+	for _, value := range query["context"] {
+		key, v, found := strings.Cut(value, "=")
+		if !found || key == "" {
+			return filter, malformedRequestError{param: "context", reason: "must be of the form key=value"}
+		}
+
+		if filter.Context == nil {
+			filter.Context = map[string]string{}
+		}
+
+		filter.Context[key] = v
+	}
+
+	return filter, nil
+}
+
+// pageLimitParam returns the number of test suite runs that are listed at once.
+func pageLimitParam(r *http.Request) (int, error) {
+	limit, err := intParam(r, "limit", defaultRunsPageSize)
+	if err != nil {
+		return 0, err
+	}
+
+	if limit < 1 || limit > maxRunsPageSize {
+		return 0, malformedRequestError{param: "limit", reason: fmt.Sprintf("must be between 1 and %d", maxRunsPageSize)}
+	}
+
+	return limit, nil
+}
+
+// maxRepeat is the maximum number of times the tests of a run can be repeated.
+const maxRepeat = 1000
+
+// repeatParam returns how often the tests of a run are repeated, 0 if they are run once.
+func repeatParam(r *http.Request) (int, error) {
+	repeat, err := intParam(r, "repeat", 0)
+	if err != nil {
+		return 0, err
+	}
+
+	if repeat < 0 || repeat > maxRepeat {
+		return 0, malformedRequestError{param: "repeat", reason: fmt.Sprintf("must be between 0 and %d", maxRepeat)}
+	}
+
+	return repeat, nil
+}
+
+func durationParam(r *http.Request, param string) (time.Duration, error) {
+	value := r.URL.Query().Get(param)
+	if value == "" {
+		return 0, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, malformedRequestError{param: param, reason: "invalid duration"}
+	}
+
+	return d, nil
 }
 
 func (s *Server) getTestSuites(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
@@ -215,11 +763,27 @@ func (s *Server) getTestSuites(w http.ResponseWriter, r *http.Request, _ httprou
 	s.writeResponse(w, r, http.StatusOK, testSuites)
 }
 
+// suiteOverviewPage are the latest runs of each suite that are shown in the test suite overview.
+var suiteOverviewPage = model.Page{Limit: 10}
+
 func (s *Server) getTestSuitesWithRuns(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 	testSuitesWitRuns := make([]model.TestSuiteWithRuns, 0, len(s.readOnlyTestSuites))
 
 	for _, suite := range s.readOnlyTestSuites {
-		runs, err := s.storage.LoadTestSuiteRunsByName(r.Context(), suite.Name)
+		runs, _, err := s.storage.ListTestSuiteRuns(r.Context(), suite.Name, model.TestSuiteRunFilter{}, suiteOverviewPage)
+		if err != nil {
+			s.httpError(w, err)
+			return
+		}
+
+		testSuitesWitRuns = append(testSuitesWitRuns, model.TestSuiteWithRuns{
+			Suite:     suite,
+			SuiteRuns: runs,
+		})
+	}
+
+	for _, suite := range s.listExternalTestSuites() {
+		runs, _, err := s.storage.ListTestSuiteRuns(r.Context(), suite.Name, model.TestSuiteRunFilter{}, suiteOverviewPage)
 		if err != nil {
 			s.httpError(w, err)
 			return
@@ -234,6 +798,10 @@ func (s *Server) getTestSuitesWithRuns(w http.ResponseWriter, r *http.Request, p
 	s.writeResponse(w, r, http.StatusOK, testSuitesWitRuns)
 }
 
+func (s *Server) getQueuedTestSuiteRuns(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	s.writeResponse(w, r, http.StatusOK, s.queue.list())
+}
+
 func (s *Server) getTestSuiteRun(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 	testRun, err := s.loadTestSuiteRun(r.Context(), p)
 	if err != nil {
@@ -241,9 +809,35 @@ func (s *Server) getTestSuiteRun(w http.ResponseWriter, r *http.Request, p httpr
 		return
 	}
 
+	testRun.QueuePosition = s.queue.position(testRun.SuiteName, testRun.ID)
+
+	if err := s.setProgress(r.Context(), &testRun); err != nil {
+		s.httpError(w, err)
+		return
+	}
+
 	s.writeResponse(w, r, http.StatusOK, testRun)
 }
 
+// getDurationStats returns the duration statistics of a test suite and its tests
+// computed from its recent runs.
+func (s *Server) getDurationStats(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	suiteName := p.ByName("suite-name")
+
+	if !slices.Contains(s.allSuiteNames(), suiteName) {
+		s.httpError(w, model.NotFoundError{})
+		return
+	}
+
+	stats, err := s.durationStats(r.Context(), suiteName, 0)
+	if err != nil {
+		s.httpError(w, err)
+		return
+	}
+
+	s.writeResponse(w, r, http.StatusOK, stats)
+}
+
 func (s *Server) getTestRunResult(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 	testRun, err := s.loadTestRuns(r.Context(), r, p)
 	if err != nil {
@@ -263,21 +857,132 @@ func (s *Server) getReady(w http.ResponseWriter, r *http.Request, p httprouter.P
 }
 
 func (s *Server) getTestSuiteRuns(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
-	testRuns, err := s.loadTestSuiteRuns(r.Context(), p)
+	filter, err := runFilterParams(r)
+	if err != nil {
+		s.httpError(w, err)
+		return
+	}
+
+	cursor, err := intParam(r, "cursor", 0)
+	if err != nil {
+		s.httpError(w, err)
+		return
+	}
+
+	limit, err := pageLimitParam(r)
 	if err != nil {
 		s.httpError(w, err)
 		return
 	}
 
+	testRuns, next, err := s.storage.ListTestSuiteRuns(r.Context(), p.ByName("suite-name"), filter, model.Page{Cursor: cursor, Limit: limit})
+	if err != nil {
+		s.httpError(w, err)
+		return
+	}
+
+	if next > 0 {
+		w.Header().Set("X-Next-Cursor", strconv.Itoa(next))
+	}
+
+	s.setQueuePositions(testRuns)
+
 	if err := s.writeResponse(w, r, http.StatusOK, testRuns); err != nil {
 		s.log.Warn("writing get test suite runs response", "error", err)
 	}
 }
 
+// searchTestSuiteRuns searches the runs of all test suites, optionally restricted to
+// the suites of a namespace or to a list of suites.
+func (s *Server) searchTestSuiteRuns(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	filter, err := runFilterParams(r)
+	if err != nil {
+		s.httpError(w, err)
+		return
+	}
+
+	cursor, err := model.ParseSearchCursor(r.URL.Query().Get("cursor"))
+	if err != nil {
+		s.httpError(w, malformedRequestError{param: "cursor", reason: "invalid cursor"})
+		return
+	}
+
+	limit, err := pageLimitParam(r)
+	if err != nil {
+		s.httpError(w, err)
+		return
+	}
+
+	filter.SuiteNames = r.URL.Query()["suite"]
+	filter.Namespace = r.URL.Query().Get("namespace")
+
+	if filter.Namespace != "" {
+		filter.SuiteNames = s.namespaceSuiteNames(filter.Namespace, filter.SuiteNames)
+
+		if len(filter.SuiteNames) == 0 {
+			s.writeResponse(w, r, http.StatusOK, []model.TestSuiteRun{})
+			return
+		}
+	}
+
+	testRuns, next, err := s.storage.SearchTestSuiteRuns(r.Context(), filter, model.SearchPage{Cursor: cursor, Limit: limit})
+	if err != nil {
+		s.httpError(w, err)
+		return
+	}
+
+	if !next.IsZero() {
+		w.Header().Set("X-Next-Cursor", next.String())
+	}
+
+	s.setQueuePositions(testRuns)
+
+	if err := s.writeResponse(w, r, http.StatusOK, testRuns); err != nil {
+		s.log.Warn("writing search test suite runs response", "error", err)
+	}
+}
+
+// getFlakyTests reports the tests that passed and failed alternately in the recent runs
+// of their test suites. The report can be restricted to a `namespace` or to some test
+// suites (`suite`, can be repeated).
+func (s *Server) getFlakyTests(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	suiteNames := r.URL.Query()["suite"]
+
+	if namespace := r.URL.Query().Get("namespace"); namespace != "" {
+		suiteNames = s.namespaceSuiteNames(namespace, suiteNames)
+	} else if len(suiteNames) == 0 {
+		suiteNames = s.allSuiteNames()
+	}
+
+	report, err := s.flakinessReport(r.Context(), suiteNames)
+	if err != nil {
+		s.httpError(w, err)
+		return
+	}
+
+	if err := s.writeResponse(w, r, http.StatusOK, report); err != nil {
+		s.log.Warn("writing flaky tests response", "error", err)
+	}
+}
+
+// setQueuePositions sets the queue position of pending runs.
+func (s *Server) setQueuePositions(runs []model.TestSuiteRun) {
+	for i := range runs {
+		if runs[i].Result == model.ResultPending {
+			runs[i].QueuePosition = s.queue.position(runs[i].SuiteName, runs[i].ID)
+		}
+	}
+}
+
 func (s *Server) writeResponse(w http.ResponseWriter, r *http.Request, status int, body any) error {
 	var err error
 
-	if headerAcceptsType(r.Header, "text/html") {
+	if runs, ok := junitRuns(body); ok && acceptsJUnit(r.Header) {
+		w.Header().Add("Content-Type", "application/xml")
+		w.WriteHeader(status)
+
+		err = junit.Write(w, runs)
+	} else if headerAcceptsType(r.Header, "text/html") {
 		w.Header().Add("Content-Type", "text/html")
 		w.WriteHeader(status)
 
@@ -286,6 +991,8 @@ func (s *Server) writeResponse(w http.ResponseWriter, r *http.Request, status in
 			err = html.RenderTestRun(t).Render(r.Context(), w)
 		case []model.ScheduledRun:
 			err = html.RenderSchedules(t).Render(r.Context(), w)
+		case model.ScheduledRun:
+			err = html.RenderSchedules([]model.ScheduledRun{t}).Render(r.Context(), w)
 		case model.TestSuiteRun:
 			err = html.RenderTestSuiteRun(t).Render(r.Context(), w)
 		case []model.TestSuiteRun:
@@ -296,11 +1003,20 @@ func (s *Server) writeResponse(w http.ResponseWriter, r *http.Request, status in
 				panic(err)
 			}
 
-			err = html.RenderTestSuiteRuns(buf.String(), t).Render(r.Context(), w)
+			err = html.RenderTestSuiteRuns(buf.String(), t, nextPageURL(w, r)).Render(r.Context(), w)
 		case []model.TestSuite:
 			err = html.RenderTestSuites(t).Render(r.Context(), w)
 		case []model.TestSuiteWithRuns:
 			err = html.RenderTestSuitesWithRuns(t).Render(r.Context(), w)
+		case []model.TestFlakiness:
+			err = html.RenderFlakyTests(t).Render(r.Context(), w)
+		case model.SuiteDurationStats:
+			err = html.RenderDurationStats(t).Render(r.Context(), w)
+		case []model.Namespace:
+			err = html.RenderNamespaces(t).Render(r.Context(), w)
+		case []model.NamespaceSuite:
+			// only called for existing namespaces which have at least one suite.
+			err = html.RenderNamespaces([]model.Namespace{{Name: t[0].Namespace, Suites: t}}).Render(r.Context(), w)
 		default:
 			return fmt.Errorf("no template available for type %v", t)
 		}
@@ -319,10 +1035,49 @@ func (s *Server) writeResponse(w http.ResponseWriter, r *http.Request, status in
 	return nil
 }
 
+// nextPageURL returns the url of the next page of a listing if the response has
+// a next cursor.
+func nextPageURL(w http.ResponseWriter, r *http.Request) string {
+	cursor := w.Header().Get("X-Next-Cursor")
+	if cursor == "" {
+		return ""
+	}
+
+	query := r.URL.Query()
+	query.Set("cursor", cursor)
+
+	return r.URL.Path + "?" + query.Encode()
+}
+
+// junitRuns returns the test suite runs of a response body that can be
+// represented as JUnit XML.
+func junitRuns(body any) ([]model.TestSuiteRun, bool) {
+	switch t := body.(type) {
+	case model.TestSuiteRun:
+		return []model.TestSuiteRun{t}, true
+	case []model.TestSuiteRun:
+		return t, true
+	default:
+		return nil, false
+	}
+}
+
+// acceptsJUnit returns true if the client explicitly asks for XML. Browsers list
+// `application/xml` as well, but always together with `text/html`.
+func acceptsJUnit(h http.Header) bool {
+	return headerAcceptsType(h, "application/xml") && !headerAcceptsType(h, "text/html")
+}
+
 func headerAcceptsType(h http.Header, mimeType string) bool {
-	accept := h.Get("Accept")
+	for _, accepted := range strings.Split(h.Get("Accept"), ",") {
+		mediaType, _, _ := strings.Cut(accepted, ";")
 
-	return strings.Contains(accept, mimeType)
+		if strings.TrimSpace(mediaType) == mimeType {
+			return true
+		}
+	}
+
+	return false
 }
 
 func (s *Server) loadTestSuite(_ *http.Request, p httprouter.Params) (model.TestSuite, error) {
@@ -355,12 +1110,6 @@ func (s *Server) loadTestRuns(ctx context.Context, _ *http.Request, p httprouter
 	return tr, nil
 }
 
-func (s *Server) loadTestSuiteRuns(ctx context.Context, p httprouter.Params) ([]model.TestSuiteRun, error) {
-	suiteName := p.ByName("suite-name")
-
-	return s.storage.LoadTestSuiteRunsByName(ctx, suiteName)
-}
-
 func (s *Server) loadTestSuiteRun(ctx context.Context, p httprouter.Params) (model.TestSuiteRun, error) {
 	suiteName := p.ByName("suite-name")
 	runID, err := strconv.Atoi(p.ByName("run-id"))
@@ -379,6 +1128,8 @@ func (s *Server) loadTestSuiteRun(ctx context.Context, p httprouter.Params) (mod
 func (s *Server) httpError(w http.ResponseWriter, err error) {
 	var notFound model.NotFoundError
 	var malformedRequest malformedRequestError
+	var conflict conflictError
+	var tooLarge *http.MaxBytesError
 
 	if errors.As(err, &notFound) {
 		w.WriteHeader(http.StatusNotFound)
@@ -387,6 +1138,14 @@ func (s *Server) httpError(w http.ResponseWriter, err error) {
 		w.WriteHeader(http.StatusBadRequest)
 		w.Write([]byte(err.Error()))
 		return
+	} else if errors.As(err, &conflict) {
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(err.Error()))
+		return
+	} else if errors.As(err, &tooLarge) {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		w.Write([]byte(fmt.Sprintf("request body exceeds the limit of %d bytes", tooLarge.Limit)))
+		return
 	}
 
 	w.WriteHeader(http.StatusInternalServerError)
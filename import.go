@@ -0,0 +1,206 @@
+package handoff
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/raphi011/handoff/internal/gotest"
+	"github.com/raphi011/handoff/internal/junit"
+	"github.com/raphi011/handoff/internal/metric"
+	"github.com/raphi011/handoff/internal/model"
+)
+
+// importFormats contains the parsers of the supported formats of test results that
+// were produced outside of handoff.
+var importFormats = map[string]func(r io.Reader) (model.TestSuiteRun, error){
+	"junit":  junit.Parse,
+	"gotest": gotest.Parse,
+}
+
+// maxImportSize is the maximum size of the test results of an import in bytes. Larger
+// imports are rejected with the status 413.
+const maxImportSize = 32 << 20
+
+// importFormat returns the import format matching a content type.
+func importFormat(contentType string) string {
+	switch {
+	case strings.Contains(contentType, "xml"):
+		return "junit"
+	case strings.Contains(contentType, "json"):
+		return "gotest"
+	default:
+		return ""
+	}
+}
+
+// loadExternalTestSuites restores the test suites whose results were imported before.
+func (s *Server) loadExternalTestSuites(ctx context.Context) error {
+	suites, err := s.storage.LoadExternalTestSuites(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.externalTestSuitesLock.Lock()
+	defer s.externalTestSuitesLock.Unlock()
+
+	for _, ts := range suites {
+		if _, ok := s.readOnlyTestSuites[ts.Name]; ok {
+			s.log.Warn("External test suite is shadowed by a test suite with the same name", "suite-name", ts.Name)
+			continue
+		}
+
+		s.externalTestSuites[ts.Name] = ts
+	}
+
+	return nil
+}
+
+// importTestSuiteRun persists the results of a test suite run that was executed outside of
+// handoff. The hooks are notified and the metrics are updated as if the run was executed by
+// handoff itself.
+func (s *Server) importTestSuiteRun(
+	ctx context.Context,
+	suiteName, namespace string,
+	tsr model.TestSuiteRun,
+	params model.RunParams,
+) (model.TestSuiteRun, error) {
+	if _, ok := s.readOnlyTestSuites[suiteName]; ok {
+		return model.TestSuiteRun{}, conflictError{reason: fmt.Sprintf("test suite %q is run by handoff, results can only be imported for external test suites", suiteName)}
+	}
+
+	if len(tsr.TestResults) == 0 {
+		return model.TestSuiteRun{}, malformedRequestError{param: "body", reason: "does not contain any test results"}
+	}
+
+	ts, err := s.saveExternalTestSuite(ctx, suiteName, namespace, tsr)
+	if err != nil {
+		return model.TestSuiteRun{}, err
+	}
+
+	suite := ts.TestSuite()
+
+	tsr.SuiteName = suiteName
+	tsr.Params = params
+	tsr.InitiatedBy = params.InitiatedBy
+	tsr.Reference = params.Reference
+	tsr.Environment = s.config.Environment
+	tsr.Scheduled = tsr.Start
+	tsr.Tests = len(tsr.LatestTestAttempts())
+	tsr.Result = tsr.ResultFromTestResults()
+	tsr.DurationInMS = tsr.TestSuiteDuration()
+	tsr.Flaky = tsr.IsFlaky()
+
+	for i := range tsr.TestResults {
+		tsr.TestResults[i].SuiteName = suiteName
+	}
+
+	tsr, err = s.insertFinishedTestSuiteRun(ctx, tsr)
+	if err != nil {
+		return model.TestSuiteRun{}, err
+	}
+
+	for _, tr := range tsr.TestResults {
+		metric.TestFinished(s.config.Instance, suite, tsr.Params, tr)
+
+		s.hooks.notifyTestFinished(suite, tsr, tr.Name, tr.Context)
+		s.hooks.notifyTestFinishedAync(suite, tsr, tr.Name, tr.Context)
+	}
+
+	s.hooks.notifyTestSuiteFinished(suite, tsr)
+	s.hooks.notifyTestSuiteFinishedAsync(suite, tsr)
+
+	metric.TestSuiteFinished(s.config.Instance, suite, tsr)
+
+	return tsr, nil
+}
+
+// insertFinishedTestSuiteRun persists a run that has already finished. Runs are inserted
+// as pending, updating it with its final result in the same transaction makes sure that
+// a pending run that would be resumed on the next startup is never committed.
+func (s *Server) insertFinishedTestSuiteRun(ctx context.Context, tsr model.TestSuiteRun) (model.TestSuiteRun, error) {
+	txCtx, err := s.storage.StartTransaction(ctx)
+	if err != nil {
+		return model.TestSuiteRun{}, fmt.Errorf("starting transaction: %w", err)
+	}
+	defer s.storage.RollbackTransaction(txCtx)
+
+	tsr.ID, err = s.storage.InsertTestSuiteRun(txCtx, tsr)
+	if err != nil {
+		return model.TestSuiteRun{}, fmt.Errorf("inserting imported test suite run: %w", err)
+	}
+
+	for i := range tsr.TestResults {
+		tsr.TestResults[i].SuiteRunID = tsr.ID
+	}
+
+	if err := s.storage.UpdateTestSuiteRun(txCtx, tsr); err != nil {
+		return model.TestSuiteRun{}, fmt.Errorf("updating imported test suite run: %w", err)
+	}
+
+	if err := s.storage.CommitTransaction(txCtx); err != nil {
+		return model.TestSuiteRun{}, fmt.Errorf("committing imported test suite run: %w", err)
+	}
+
+	return tsr, nil
+}
+
+// saveExternalTestSuite creates or updates an external test suite with the tests of an
+// imported run. The namespace of an existing suite is kept if none is passed in.
+func (s *Server) saveExternalTestSuite(
+	ctx context.Context,
+	suiteName, namespace string,
+	tsr model.TestSuiteRun,
+) (model.ExternalTestSuite, error) {
+	s.externalTestSuitesLock.Lock()
+	defer s.externalTestSuitesLock.Unlock()
+
+	ts, ok := s.externalTestSuites[suiteName]
+	if !ok {
+		ts = model.ExternalTestSuite{Name: suiteName}
+	}
+
+	if namespace != "" {
+		ts.Namespace = namespace
+	}
+
+	tests := slices.Clone(ts.Tests)
+
+	for _, tr := range tsr.TestResults {
+		if !slices.Contains(tests, tr.Name) {
+			tests = append(tests, tr.Name)
+		}
+	}
+
+	sort.Strings(tests)
+	ts.Tests = tests
+
+	if err := s.storage.SaveExternalTestSuite(ctx, ts); err != nil {
+		return model.ExternalTestSuite{}, err
+	}
+
+	s.externalTestSuites[suiteName] = ts
+
+	return ts, nil
+}
+
+// listExternalTestSuites returns all external test suites sorted by name.
+func (s *Server) listExternalTestSuites() []model.TestSuite {
+	s.externalTestSuitesLock.Lock()
+	defer s.externalTestSuitesLock.Unlock()
+
+	suites := make([]model.TestSuite, 0, len(s.externalTestSuites))
+
+	for _, ts := range s.externalTestSuites {
+		suites = append(suites, ts.TestSuite())
+	}
+
+	sort.Slice(suites, func(i, j int) bool {
+		return suites[i].Name < suites[j].Name
+	})
+
+	return suites
+}
@@ -0,0 +1,173 @@
+// Package gotest maps the output of `go test -json` to test suite runs.
+package gotest
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/raphi011/handoff/internal/model"
+)
+
+// Event is a single line of the `go test -json` output,
+// see https://pkg.go.dev/cmd/test2json.
+type Event struct {
+	Time    time.Time `json:"Time"`
+	Action  string    `json:"Action"`
+	Package string    `json:"Package"`
+	Test    string    `json:"Test"`
+	Elapsed float64   `json:"Elapsed"`
+	Output  string    `json:"Output"`
+}
+
+// results maps the actions that end a test to their result.
+var results = map[string]model.Result{
+	"pass": model.ResultPassed,
+	"fail": model.ResultFailed,
+	"skip": model.ResultSkipped,
+}
+
+type test struct {
+	pkg      string
+	run      model.TestRun
+	logs     strings.Builder
+	finished bool
+}
+
+// Parse reads the `go test -json` event stream and maps it to a test suite run. Every
+// (sub)test becomes a test run, test names are prefixed with the package if the stream
+// contains more than one package. Packages that fail without a failing test (e.g. because
+// they do not compile) are added as failed test runs named after the package.
+func Parse(r io.Reader) (model.TestSuiteRun, error) {
+	tests := map[string]*test{}
+	order := []string{}
+
+	packageOutput := map[string]*strings.Builder{}
+	packageFailed := map[string]bool{}
+	packageEnd := map[string]time.Time{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var e Event
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			return model.TestSuiteRun{}, fmt.Errorf("gotest: invalid event %q: %w", line, err)
+		}
+
+		if e.Test == "" {
+			if _, ok := packageOutput[e.Package]; !ok {
+				packageOutput[e.Package] = &strings.Builder{}
+			}
+
+			switch e.Action {
+			case "output":
+				packageOutput[e.Package].WriteString(e.Output)
+			case "fail":
+				packageFailed[e.Package] = true
+				packageEnd[e.Package] = e.Time
+			}
+
+			continue
+		}
+
+		key := e.Package + "\x00" + e.Test
+
+		t, ok := tests[key]
+		if !ok {
+			t = &test{pkg: e.Package, run: model.TestRun{
+				Name:    e.Test,
+				Attempt: 1,
+				Result:  model.ResultPending,
+				Start:   e.Time,
+				Context: model.TestContext{"package": e.Package},
+			}}
+			tests[key] = t
+			order = append(order, key)
+		}
+
+		switch e.Action {
+		case "output":
+			t.logs.WriteString(e.Output)
+		case "pass", "fail", "skip":
+			t.finished = true
+			t.run.End = e.Time
+			t.run.DurationInMS = int64(e.Elapsed * 1000)
+			t.run.Result = results[e.Action]
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return model.TestSuiteRun{}, fmt.Errorf("gotest: %w", err)
+	}
+
+	if len(tests) == 0 && len(packageOutput) == 0 {
+		return model.TestSuiteRun{}, errors.New("gotest: no test events found")
+	}
+
+	tsr := model.TestSuiteRun{
+		TestResults: []model.TestRun{},
+	}
+
+	packages := map[string]bool{}
+	for _, t := range tests {
+		packages[t.pkg] = true
+	}
+
+	failedTests := map[string]bool{}
+
+	for _, key := range order {
+		t := tests[key]
+		t.run.Logs = t.logs.String()
+
+		if len(packages) > 1 {
+			t.run.Name = t.pkg + "." + t.run.Name
+		}
+
+		if !t.finished {
+			// the test binary exited (e.g. panicked or timed out) before the test finished
+			t.run.Result = model.ResultFailed
+			t.run.End = packageEnd[t.pkg]
+		}
+
+		if t.run.Result == model.ResultFailed {
+			failedTests[t.pkg] = true
+		}
+
+		tsr.TestResults = append(tsr.TestResults, t.run)
+	}
+
+	for pkg, failed := range packageFailed {
+		if failed && !failedTests[pkg] {
+			tsr.TestResults = append(tsr.TestResults, model.TestRun{
+				Name:    pkg,
+				Attempt: 1,
+				Result:  model.ResultFailed,
+				Logs:    packageOutput[pkg].String(),
+				Start:   packageEnd[pkg],
+				End:     packageEnd[pkg],
+				Context: model.TestContext{"package": pkg},
+			})
+		}
+	}
+
+	for _, tr := range tsr.TestResults {
+		if tsr.Start.IsZero() || (!tr.Start.IsZero() && tr.Start.Before(tsr.Start)) {
+			tsr.Start = tr.Start
+		}
+		if tr.End.After(tsr.End) {
+			tsr.End = tr.End
+		}
+	}
+
+	return tsr, nil
+}
@@ -42,3 +42,15 @@ func pluralize(n int) string {
 	}
 	return ""
 }
+
+// FormatDurationMS formats a duration in milliseconds, durations of a second or longer
+// are rounded to a tenth of a second.
+func FormatDurationMS(ms int64) string {
+	d := time.Duration(ms) * time.Millisecond
+
+	if d < time.Second {
+		return d.String()
+	}
+
+	return d.Round(100 * time.Millisecond).String()
+}
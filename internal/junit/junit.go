@@ -0,0 +1,234 @@
+// Package junit converts test suite runs to the JUnit XML format that is understood by most
+// CI systems (e.g. GitLab, Jenkins). Retried tests are reported with the `flakyFailure` and
+// `rerunFailure` elements of the maven surefire schema.
+package junit
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/raphi011/handoff/internal/model"
+)
+
+type Testsuites struct {
+	XMLName  xml.Name    `xml:"testsuites"`
+	Name     string      `xml:"name,attr,omitempty"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Skipped  int         `xml:"skipped,attr"`
+	Time     seconds     `xml:"time,attr"`
+	Suites   []Testsuite `xml:"testsuite"`
+}
+
+type Testsuite struct {
+	Name       string     `xml:"name,attr"`
+	ID         int        `xml:"id,attr"`
+	Tests      int        `xml:"tests,attr"`
+	Failures   int        `xml:"failures,attr"`
+	Errors     int        `xml:"errors,attr"`
+	Skipped    int        `xml:"skipped,attr"`
+	Time       seconds    `xml:"time,attr"`
+	Timestamp  string     `xml:"timestamp,attr,omitempty"`
+	Properties []Property `xml:"properties>property,omitempty"`
+	Testcases  []Testcase `xml:"testcase"`
+	SystemOut  string     `xml:"system-out,omitempty"`
+	SystemErr  string     `xml:"system-err,omitempty"`
+}
+
+type Property struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type Testcase struct {
+	Name          string  `xml:"name,attr"`
+	Classname     string  `xml:"classname,attr"`
+	Time          seconds `xml:"time,attr"`
+	Failure       *Result `xml:"failure,omitempty"`
+	Error         *Result `xml:"error,omitempty"`
+	Skipped       *Result `xml:"skipped,omitempty"`
+	FlakyFailures []Rerun `xml:"flakyFailure,omitempty"`
+	RerunFailures []Rerun `xml:"rerunFailure,omitempty"`
+	// Properties are not part of every JUnit schema, but e.g. understood by Jenkins.
+	Properties []Property `xml:"properties>property,omitempty"`
+	SystemOut  string     `xml:"system-out,omitempty"`
+}
+
+type Result struct {
+	Message string `xml:"message,attr,omitempty"`
+	Type    string `xml:"type,attr,omitempty"`
+	// Text is e.g. the stack trace of a failure.
+	Text string `xml:",chardata"`
+}
+
+// Rerun is a failed attempt of a test that was retried.
+type Rerun struct {
+	Message    string `xml:"message,attr,omitempty"`
+	Type       string `xml:"type,attr,omitempty"`
+	StackTrace string `xml:"stackTrace,omitempty"`
+	SystemOut  string `xml:"system-out,omitempty"`
+}
+
+// seconds is a duration in milliseconds that is formatted in seconds.
+type seconds int64
+
+func (s seconds) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%.3f", float64(s)/1000)), nil
+}
+
+func (s *seconds) UnmarshalText(text []byte) error {
+	f, err := strconv.ParseFloat(string(text), 64)
+	if err != nil {
+		return err
+	}
+
+	*s = seconds(math.Round(f * 1000))
+
+	return nil
+}
+
+// Write writes the test suite runs as JUnit XML document.
+func Write(w io.Writer, runs []model.TestSuiteRun) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+
+	if err := enc.Encode(FromTestSuiteRuns(runs)); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+
+	return err
+}
+
+func FromTestSuiteRuns(runs []model.TestSuiteRun) Testsuites {
+	suites := Testsuites{
+		Name:   "handoff",
+		Suites: []Testsuite{},
+	}
+
+	for _, tsr := range runs {
+		ts := FromTestSuiteRun(tsr)
+
+		suites.Tests += ts.Tests
+		suites.Failures += ts.Failures
+		suites.Skipped += ts.Skipped
+		suites.Time += ts.Time
+		suites.Suites = append(suites.Suites, ts)
+	}
+
+	return suites
+}
+
+// FromTestSuiteRun maps a test suite run to a JUnit test suite. Every test is reported
+// once with the result of its latest attempt, previous failed attempts are added as
+// flaky failures if the test eventually passed or rerun failures otherwise. Soft failures
+// do not fail the run, which is why they are reported as passed tests with a `softFailure`
+// property, their logs are part of the system-out of the test case. The setup logs are
+// reported as the system-out and the teardown logs as the system-err of the suite.
+func FromTestSuiteRun(tsr model.TestSuiteRun) Testsuite {
+	ts := Testsuite{
+		Name:      tsr.SuiteName,
+		ID:        tsr.ID,
+		Time:      seconds(tsr.DurationInMS),
+		SystemOut: tsr.SetupLogs,
+		SystemErr: tsr.TeardownLogs,
+		Testcases: []Testcase{},
+	}
+
+	if !tsr.Start.IsZero() {
+		ts.Timestamp = tsr.Start.UTC().Format("2006-01-02T15:04:05")
+	}
+
+	for _, p := range []Property{
+		{Name: "result", Value: string(tsr.Result)},
+		{Name: "environment", Value: tsr.Environment},
+		{Name: "reference", Value: tsr.Reference},
+		{Name: "initiatedBy", Value: tsr.InitiatedBy},
+		{Name: "scheduleName", Value: tsr.ScheduleName},
+		{Name: "teardownResult", Value: string(tsr.TeardownResult)},
+	} {
+		if p.Value != "" {
+			ts.Properties = append(ts.Properties, p)
+		}
+	}
+
+	for _, tr := range tsr.LatestTestAttempts() {
+		tc := fromTestRun(tr)
+
+		attempts := tsr.TestRunsByName(tr.Name)
+		sort.Slice(attempts, func(i, j int) bool {
+			return attempts[i].Attempt < attempts[j].Attempt
+		})
+
+		for _, attempt := range attempts {
+			if attempt.Attempt >= tr.Attempt || attempt.Result != model.ResultFailed {
+				continue
+			}
+
+			rerun := Rerun{
+				Message:   fmt.Sprintf("attempt %d failed", attempt.Attempt),
+				SystemOut: attempt.Logs,
+			}
+
+			if tr.Result == model.ResultPassed {
+				tc.FlakyFailures = append(tc.FlakyFailures, rerun)
+			} else {
+				tc.RerunFailures = append(tc.RerunFailures, rerun)
+			}
+		}
+
+		ts.Tests++
+
+		switch {
+		case tc.Failure != nil:
+			ts.Failures++
+		case tc.Skipped != nil:
+			ts.Skipped++
+		}
+
+		ts.Testcases = append(ts.Testcases, tc)
+	}
+
+	return ts
+}
+
+// softFailureProperty is the name of the test case property that marks soft failures.
+const softFailureProperty = "softFailure"
+
+func fromTestRun(tr model.TestRun) Testcase {
+	tc := Testcase{
+		Name:      tr.Name,
+		Classname: tr.SuiteName,
+		Time:      seconds(tr.DurationInMS),
+		SystemOut: tr.Logs,
+	}
+
+	switch {
+	case tr.Result == model.ResultFailed && tr.SoftFailure:
+		tc.Properties = []Property{{Name: softFailureProperty, Value: "true"}}
+	case tr.Result == model.ResultFailed:
+		tc.Failure = &Result{Message: firstLine(tr.Logs), Type: "failure"}
+	case tr.Result == model.ResultSkipped:
+		tc.Skipped = &Result{Message: firstLine(tr.Logs)}
+	case tr.Result == model.ResultPending:
+		tc.Skipped = &Result{Message: "test has not been run yet"}
+	}
+
+	return tc
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+
+	return line
+}
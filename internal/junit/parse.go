@@ -0,0 +1,187 @@
+package junit
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/raphi011/handoff/internal/model"
+)
+
+// timestampLayouts are the layouts of the `timestamp` attribute written by common
+// JUnit reporters, some of them omit the timezone.
+var timestampLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05"}
+
+// Parse reads a JUnit XML document, with either a `testsuites` or a single `testsuite`
+// root element, and maps it to a test suite run. The test cases of all test suites are
+// added to the run and their names are prefixed with the class name. Previous attempts
+// of retried tests (`flakyFailure` and `rerunFailure`) are added as failed attempts.
+func Parse(r io.Reader) (model.TestSuiteRun, error) {
+	suites, err := decode(r)
+	if err != nil {
+		return model.TestSuiteRun{}, err
+	}
+
+	tsr := model.TestSuiteRun{
+		TestResults: []model.TestRun{},
+	}
+
+	names := map[string]int{}
+	setupLogs := []string{}
+
+	for _, ts := range suites {
+		start := parseTimestamp(ts.Timestamp)
+
+		if tsr.Start.IsZero() || start.Before(tsr.Start) {
+			tsr.Start = start
+		}
+
+		if ts.SystemOut != "" {
+			setupLogs = append(setupLogs, ts.SystemOut)
+		}
+
+		for _, tc := range ts.Testcases {
+			name := tc.Name
+			if tc.Classname != "" && tc.Classname != ts.Name {
+				name = tc.Classname + "." + tc.Name
+			}
+
+			// parameterized tests can be reported multiple times with the same name
+			names[name]++
+			if n := names[name]; n > 1 {
+				name = fmt.Sprintf("%s (%d)", name, n)
+			}
+
+			for _, rerun := range append(tc.FlakyFailures, tc.RerunFailures...) {
+				tsr.TestResults = append(tsr.TestResults, model.TestRun{
+					Name:    name,
+					Attempt: len(tsr.TestRunsByName(name)) + 1,
+					Result:  model.ResultFailed,
+					Logs:    joinLogs(rerun.Message, rerun.StackTrace, rerun.SystemOut),
+					Start:   start,
+					End:     start,
+					Context: model.TestContext{},
+				})
+			}
+
+			end := start.Add(time.Duration(tc.Time) * time.Millisecond)
+
+			tr := model.TestRun{
+				Name:         name,
+				Attempt:      len(tsr.TestRunsByName(name)) + 1,
+				Result:       model.ResultPassed,
+				Start:        start,
+				End:          end,
+				DurationInMS: int64(tc.Time),
+				Context:      model.TestContext{},
+			}
+
+			switch {
+			case tc.Failure != nil:
+				tr.Result = model.ResultFailed
+				tr.Logs = joinLogs(tc.Failure.Message, tc.Failure.Text, tc.SystemOut)
+			case tc.Error != nil:
+				tr.Result = model.ResultFailed
+				tr.Logs = joinLogs(tc.Error.Message, tc.Error.Text, tc.SystemOut)
+			case tc.Skipped != nil:
+				tr.Result = model.ResultSkipped
+				tr.Logs = joinLogs(tc.Skipped.Message, tc.Skipped.Text, tc.SystemOut)
+			case tc.softFailure():
+				tr.Result = model.ResultFailed
+				tr.Logs = tc.SystemOut
+				tr.SoftFailure = true
+			default:
+				tr.Logs = tc.SystemOut
+			}
+
+			tsr.TestResults = append(tsr.TestResults, tr)
+
+			if end.After(tsr.End) {
+				tsr.End = end
+			}
+
+			start = end
+		}
+	}
+
+	tsr.SetupLogs = strings.Join(setupLogs, "\n")
+
+	return tsr, nil
+}
+
+func decode(r io.Reader) ([]Testsuite, error) {
+	dec := xml.NewDecoder(r)
+
+	for {
+		token, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("junit: document has no root element")
+		} else if err != nil {
+			return nil, fmt.Errorf("junit: %w", err)
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch start.Name.Local {
+		case "testsuites":
+			var suites Testsuites
+			if err := dec.DecodeElement(&suites, &start); err != nil {
+				return nil, fmt.Errorf("junit: %w", err)
+			}
+
+			return suites.Suites, nil
+		case "testsuite":
+			var suite Testsuite
+			if err := dec.DecodeElement(&suite, &start); err != nil {
+				return nil, fmt.Errorf("junit: %w", err)
+			}
+
+			return []Testsuite{suite}, nil
+		default:
+			return nil, fmt.Errorf("junit: unexpected root element %q", start.Name.Local)
+		}
+	}
+}
+
+func parseTimestamp(timestamp string) time.Time {
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, timestamp); err == nil {
+			return t
+		}
+	}
+
+	return time.Now()
+}
+
+func joinLogs(parts ...string) string {
+	logs := []string{}
+
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			logs = append(logs, p)
+		}
+	}
+
+	if len(logs) == 0 {
+		return ""
+	}
+
+	return strings.Join(logs, "\n") + "\n"
+}
+
+// softFailure returns true if the test case is a soft failure reported by handoff.
+func (tc Testcase) softFailure() bool {
+	for _, p := range tc.Properties {
+		if p.Name == softFailureProperty && p.Value == "true" {
+			return true
+		}
+	}
+
+	return false
+}
@@ -1,6 +1,8 @@
 package metric
 
 import (
+	"sync"
+
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/raphi011/handoff/internal/model"
@@ -12,6 +14,11 @@ var (
 		Help: "The number of test suites currently running",
 	}, []string{"instance", "namespace", "suite_name"})
 
+	TestSuitesQueued = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "handoff_testsuites_queued",
+		Help: "The number of test suite runs waiting to be started",
+	}, []string{"instance", "namespace", "suite_name"})
+
 	TestSuitesRun = promauto.NewCounterVec(prometheus.CounterOpts{Name: "handoff_testsuites_started_total",
 		Help: "The number of test suite runs",
 	}, []string{"instance", "namespace", "suite_name", "result", "flaky"})
@@ -20,8 +27,96 @@ var (
 		Name: "handoff_tests_run_total",
 		Help: "The number of tests run",
 	}, []string{"instance", "namespace", "suite_name", "result"})
+
+	TestSuiteDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "handoff_testsuite_duration_seconds",
+		Help:    "The time it took to run a test suite from its start to its end",
+		Buckets: prometheus.ExponentialBuckets(1, 2, 12),
+	}, []string{"instance", "namespace", "suite_name", "result"})
+
+	TestSuiteSetupFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "handoff_testsuite_setup_failures_total",
+		Help: "The number of test suite runs whose setup failed",
+	}, []string{"instance", "namespace", "suite_name"})
+
+	TestSuiteTeardownFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "handoff_testsuite_teardown_failures_total",
+		Help: "The number of test suite runs whose teardown failed",
+	}, []string{"instance", "namespace", "suite_name"})
+
+	TestAttempts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "handoff_test_attempts_total",
+		Help: "The number of attempts of a test",
+	}, []string{"instance", "namespace", "suite_name", "test_name", "result"})
+
+	TestRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "handoff_test_retries_total",
+		Help: "The number of attempts of a test that were retries of a previous attempt",
+	}, []string{"instance", "namespace", "suite_name", "test_name"})
+
+	TestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "handoff_test_duration_seconds",
+		Help:    "The duration of a test attempt",
+		Buckets: prometheus.ExponentialBuckets(0.05, 2, 14),
+	}, []string{"instance", "namespace", "suite_name", "test_name", "result"})
+
+	SpanDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "handoff_test_span_duration_seconds",
+		Help:    "The duration of the spans created by a test",
+		Buckets: prometheus.ExponentialBuckets(0.005, 2, 14),
+	}, []string{"instance", "namespace", "suite_name", "test_name", "span_name"})
+
+	TestLastResult = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "handoff_test_last_result",
+		Help: "The result of the latest attempt of a test that passed or failed, 1 if it passed and 0 if it failed",
+	}, []string{"instance", "namespace", "suite_name", "test_name"})
+
+	TestLastRunTimestamp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "handoff_test_last_run_timestamp_seconds",
+		Help: "The unix time the latest attempt of a test that passed or failed ended at",
+	}, []string{"instance", "namespace", "suite_name", "test_name"})
+
+	TestLastSuccessTimestamp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "handoff_test_last_success_timestamp_seconds",
+		Help: "The unix time the latest passed attempt of a test ended at",
+	}, []string{"instance", "namespace", "suite_name", "test_name"})
 )
 
+// MaxSpanNames is the number of distinct span names per test that are used as the value
+// of the `span_name` label. Span names are chosen freely by the tests, the durations of
+// spans with further names are recorded as "other" to keep the number of series bounded.
+const MaxSpanNames = 20
+
+var spanNames = struct {
+	sync.Mutex
+	// names contains the span names used as label values per suite and test name.
+	names map[[2]string]map[string]bool
+}{names: map[[2]string]map[string]bool{}}
+
+// spanNameLabel returns the value of the `span_name` label of a span.
+func spanNameLabel(suiteName, testName, spanName string) string {
+	spanNames.Lock()
+	defer spanNames.Unlock()
+
+	key := [2]string{suiteName, testName}
+
+	names, ok := spanNames.names[key]
+	if !ok {
+		names = map[string]bool{}
+		spanNames.names[key] = names
+	}
+
+	if !names[spanName] {
+		if len(names) >= MaxSpanNames {
+			return "other"
+		}
+
+		names[spanName] = true
+	}
+
+	return spanName
+}
+
 func TestSuiteFinished(instance string, suite model.TestSuite, tsr model.TestSuiteRun) {
 	flaky := "0"
 	if tsr.Flaky {
@@ -29,4 +124,55 @@ func TestSuiteFinished(instance string, suite model.TestSuite, tsr model.TestSui
 	}
 
 	TestSuitesRun.WithLabelValues(instance, suite.Namespace, suite.Name, string(tsr.Result), flaky).Inc()
+
+	if !tsr.Start.IsZero() && tsr.End.After(tsr.Start) {
+		TestSuiteDuration.WithLabelValues(instance, suite.Namespace, suite.Name, string(tsr.Result)).
+			Observe(tsr.End.Sub(tsr.Start).Seconds())
+	}
+}
+
+// TestFinished records the metrics of a finished test attempt and of the spans it created.
+// Attempts of runs that repeat their tests are not counted as retries.
+func TestFinished(instance string, suite model.TestSuite, params model.RunParams, tr model.TestRun) {
+	TestRunsTotal.WithLabelValues(instance, suite.Namespace, suite.Name, string(tr.Result)).Inc()
+	TestAttempts.WithLabelValues(instance, suite.Namespace, suite.Name, tr.Name, string(tr.Result)).Inc()
+
+	if tr.Attempt > 1 && params.Repeat == 0 {
+		TestRetries.WithLabelValues(instance, suite.Namespace, suite.Name, tr.Name).Inc()
+	}
+
+	if tr.Result != model.ResultPassed && tr.Result != model.ResultFailed {
+		return
+	}
+
+	TestDuration.WithLabelValues(instance, suite.Namespace, suite.Name, tr.Name, string(tr.Result)).
+		Observe(float64(tr.DurationInMS) / 1000)
+
+	for _, span := range tr.Spans {
+		if span.End != nil {
+			SpanDuration.WithLabelValues(instance, suite.Namespace, suite.Name, tr.Name, spanNameLabel(suite.Name, tr.Name, span.Name)).
+				Observe(span.End.Sub(span.Start).Seconds())
+		}
+	}
+
+	end := float64(tr.End.Unix())
+
+	TestLastRunTimestamp.WithLabelValues(instance, suite.Namespace, suite.Name, tr.Name).Set(end)
+
+	if tr.Result == model.ResultPassed {
+		TestLastResult.WithLabelValues(instance, suite.Namespace, suite.Name, tr.Name).Set(1)
+		TestLastSuccessTimestamp.WithLabelValues(instance, suite.Namespace, suite.Name, tr.Name).Set(end)
+	} else {
+		TestLastResult.WithLabelValues(instance, suite.Namespace, suite.Name, tr.Name).Set(0)
+	}
+}
+
+// SetupFailed records a test suite run whose setup failed.
+func SetupFailed(instance string, suite model.TestSuite) {
+	TestSuiteSetupFailures.WithLabelValues(instance, suite.Namespace, suite.Name).Inc()
+}
+
+// TeardownFailed records a test suite run whose teardown failed.
+func TeardownFailed(instance string, suite model.TestSuite) {
+	TestSuiteTeardownFailures.WithLabelValues(instance, suite.Namespace, suite.Name).Inc()
 }
@@ -0,0 +1,138 @@
+package model
+
+import "slices"
+
+// MinBaselineRuns is the number of finished runs that are needed before the duration
+// of a run is compared to the durations of previous runs.
+const MinBaselineRuns = 5
+
+// minSlowdownMS is how much longer than the baseline a run has to take at least to be
+// slow, this avoids flagging runs of very fast test suites because of noise.
+const minSlowdownMS = 500
+
+// DurationStats summarizes the durations of previous runs of a test or test suite.
+type DurationStats struct {
+	// Runs is the number of durations the statistics are computed from.
+	Runs  int   `json:"runs"`
+	P50MS int64 `json:"p50Ms"`
+	P90MS int64 `json:"p90Ms"`
+	MaxMS int64 `json:"maxMs"`
+}
+
+// SuiteDurationStats are the duration statistics of a test suite and its tests.
+type SuiteDurationStats struct {
+	SuiteName string `json:"suiteName"`
+	// Suite summarizes the durations (`TestSuiteRun.DurationInMS`) of the runs.
+	Suite DurationStats `json:"suite"`
+	// Tests summarizes the durations of the attempts of each test.
+	Tests map[string]DurationStats `json:"tests"`
+}
+
+// ComputeDurationStats computes the duration statistics of the runs of a test suite.
+// Only runs and test attempts that passed or failed are taken into account.
+func ComputeDurationStats(suiteName string, runs []TestSuiteRun) SuiteDurationStats {
+	suite := []int64{}
+	tests := map[string][]int64{}
+
+	for _, tsr := range runs {
+		if tsr.Result != ResultPassed && tsr.Result != ResultFailed {
+			continue
+		}
+
+		suite = append(suite, tsr.DurationInMS)
+
+		for _, tr := range tsr.TestResults {
+			if tr.Result == ResultPassed || tr.Result == ResultFailed {
+				tests[tr.Name] = append(tests[tr.Name], tr.DurationInMS)
+			}
+		}
+	}
+
+	stats := SuiteDurationStats{
+		SuiteName: suiteName,
+		Suite:     durationStats(suite),
+		Tests:     map[string]DurationStats{},
+	}
+
+	for name, durations := range tests {
+		stats.Tests[name] = durationStats(durations)
+	}
+
+	return stats
+}
+
+func durationStats(durations []int64) DurationStats {
+	if len(durations) == 0 {
+		return DurationStats{}
+	}
+
+	slices.Sort(durations)
+
+	return DurationStats{
+		Runs:  len(durations),
+		P50MS: percentile(durations, 50),
+		P90MS: percentile(durations, 90),
+		MaxMS: durations[len(durations)-1],
+	}
+}
+
+// percentile returns the nearest-rank percentile of sorted durations.
+func percentile(sorted []int64, p int) int64 {
+	rank := (p*len(sorted) + 99) / 100
+
+	return sorted[max(rank, 1)-1]
+}
+
+// IsSlow returns true if a duration is more than `factor` times the 90th percentile of
+// the baseline (and at least half a second longer). Without enough baseline runs no
+// duration is slow.
+func (s DurationStats) IsSlow(durationInMS int64, factor float64) bool {
+	if s.Runs < MinBaselineRuns || factor <= 0 {
+		return false
+	}
+
+	return float64(durationInMS) > float64(s.P90MS)*factor && durationInMS-s.P90MS >= minSlowdownMS
+}
+
+// RunProgress estimates the progress of a pending test suite run from the durations of
+// previous runs.
+type RunProgress struct {
+	// Typical are the duration statistics of previous runs of the test suite.
+	Typical DurationStats `json:"typical"`
+	// Percent is the estimated share of the run that has been completed (0-100).
+	Percent int `json:"percent"`
+	// RemainingMS is the estimated time in milliseconds until the run has finished.
+	RemainingMS int64 `json:"remainingMs"`
+}
+
+// EstimateProgress estimates the progress of a run. The tests that have finished count
+// with their actual duration, pending tests with the median duration of their previous
+// attempts. It returns nil if there are no previous runs to estimate from.
+func EstimateProgress(tsr TestSuiteRun, stats SuiteDurationStats) *RunProgress {
+	if stats.Suite.Runs == 0 {
+		return nil
+	}
+
+	done, remaining := int64(0), int64(0)
+
+	for _, tr := range tsr.latestTestAttempts() {
+		switch tr.Result {
+		case ResultPending:
+			remaining += stats.Tests[tr.Name].P50MS
+		case ResultPassed, ResultFailed:
+			done += tr.DurationInMS
+		}
+	}
+
+	progress := &RunProgress{
+		Typical:     stats.Suite,
+		Percent:     100,
+		RemainingMS: remaining,
+	}
+
+	if done+remaining > 0 {
+		progress.Percent = int(done * 100 / (done + remaining))
+	}
+
+	return progress
+}
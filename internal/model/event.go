@@ -0,0 +1,40 @@
+package model
+
+import "time"
+
+type RunEventType string
+
+const (
+	// RunEventTestStarted is published when a test (attempt) starts running.
+	RunEventTestStarted RunEventType = "test-started"
+	// RunEventTestFinished is published when a test (attempt) has a result.
+	RunEventTestFinished RunEventType = "test-finished"
+	// RunEventLog is published for every log line written by a test.
+	RunEventLog RunEventType = "log"
+	// RunEventSpan is published when a test starts a new span.
+	RunEventSpan RunEventType = "span"
+	// RunEventRunFinished is published once the test suite run is done or was
+	// interrupted, it is the last event of a run.
+	RunEventRunFinished RunEventType = "run-finished"
+)
+
+// RunEvent describes the progress of a running test suite run.
+type RunEvent struct {
+	Type      RunEventType `json:"type"`
+	SuiteName string       `json:"suiteName"`
+	RunID     int          `json:"runId"`
+	Time      time.Time    `json:"time"`
+
+	// TestName and Attempt are set for all events but `run-finished`.
+	TestName string `json:"testName,omitempty"`
+	Attempt  int    `json:"attempt,omitempty"`
+
+	// Result is set for `test-finished` and `run-finished` events.
+	Result Result `json:"result,omitempty"`
+
+	// Log is the log line of `log` events.
+	Log string `json:"log,omitempty"`
+
+	// Span is the started span of `span` events.
+	Span *Span `json:"span,omitempty"`
+}
@@ -0,0 +1,161 @@
+package model
+
+import (
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TestSuiteRunFilter restricts the test suite runs that are listed. Empty fields
+// match all runs.
+type TestSuiteRunFilter struct {
+	Result         Result
+	Environment    string
+	InitiatedBy    string
+	Reference      string
+	ScheduleName   string
+	IdempotencyKey string
+
+	// Results restricts the runs to the runs with one of these results.
+	Results []Result
+
+	// SuiteNames restricts the runs to the runs of these test suites.
+	SuiteNames []string
+
+	// Namespace restricts the runs to the test suites of a namespace. It is resolved to
+	// `SuiteNames` by the server and not used by the storage.
+	Namespace string
+
+	// TestName only matches runs that contain a test with this name.
+	TestName string
+
+	// Context only matches runs that contain a test whose context has these values (e.g.
+	// set with `T.SetValue`). Values are compared with their string representation.
+	Context map[string]string
+
+	// From and To limit the time the runs were scheduled at, both are inclusive.
+	From time.Time
+	To   time.Time
+
+	// Flaky, if set, only matches runs that are (or are not) flaky.
+	Flaky *bool
+}
+
+// Matches returns true if the test suite run matches all conditions of the filter.
+func (f TestSuiteRunFilter) Matches(tsr TestSuiteRun) bool {
+	switch {
+	case f.Result != "" && tsr.Result != f.Result:
+		return false
+	case len(f.Results) > 0 && !slices.Contains(f.Results, tsr.Result):
+		return false
+	case f.Environment != "" && tsr.Environment != f.Environment:
+		return false
+	case f.InitiatedBy != "" && tsr.InitiatedBy != f.InitiatedBy:
+		return false
+	case f.Reference != "" && tsr.Reference != f.Reference:
+		return false
+	case f.ScheduleName != "" && tsr.ScheduleName != f.ScheduleName:
+		return false
+	case f.IdempotencyKey != "" && tsr.IdempotencyKey != f.IdempotencyKey:
+		return false
+	case len(f.SuiteNames) > 0 && !slices.Contains(f.SuiteNames, tsr.SuiteName):
+		return false
+	case !f.From.IsZero() && tsr.Scheduled.Before(f.From):
+		return false
+	case !f.To.IsZero() && tsr.Scheduled.After(f.To):
+		return false
+	case f.Flaky != nil && tsr.Flaky != *f.Flaky:
+		return false
+	case f.TestName != "" && !slices.ContainsFunc(tsr.TestResults, func(tr TestRun) bool {
+		return tr.Name == f.TestName
+	}):
+		return false
+	}
+
+	for key, value := range f.Context {
+		found := slices.ContainsFunc(tsr.TestResults, func(tr TestRun) bool {
+			v, ok := tr.Context[key]
+			return ok && fmt.Sprint(v) == value
+		})
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Page selects a page of a listing that is ordered from newest to oldest. Cursor is the
+// id of the last run of the previous page, 0 starts at the newest run.
+type Page struct {
+	Cursor int
+	Limit  int
+}
+
+// SearchCursor is the position of a run in the results of a search across test suites.
+// The results are ordered by the time the runs were scheduled at, their suite name and
+// their id, all descending.
+type SearchCursor struct {
+	Scheduled time.Time
+	SuiteName string
+	ID        int
+}
+
+// SearchCursorOf returns the cursor that points to a run.
+func SearchCursorOf(tsr TestSuiteRun) SearchCursor {
+	return SearchCursor{Scheduled: tsr.Scheduled, SuiteName: tsr.SuiteName, ID: tsr.ID}
+}
+
+// IsZero returns true if the cursor does not point to a run.
+func (c SearchCursor) IsZero() bool {
+	return c.SuiteName == ""
+}
+
+// String encodes the cursor as an opaque string that can be passed as a url parameter.
+func (c SearchCursor) String() string {
+	if c.IsZero() {
+		return ""
+	}
+
+	return base64.RawURLEncoding.EncodeToString(
+		[]byte(fmt.Sprintf("%d:%d:%s", c.Scheduled.UnixNano(), c.ID, c.SuiteName)))
+}
+
+// ParseSearchCursor parses a cursor encoded by `SearchCursor.String()`.
+func ParseSearchCursor(s string) (SearchCursor, error) {
+	if s == "" {
+		return SearchCursor{}, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return SearchCursor{}, fmt.Errorf("invalid cursor: %w", err)
+	}
+
+	parts := strings.SplitN(string(data), ":", 3)
+	if len(parts) != 3 || parts[2] == "" {
+		return SearchCursor{}, fmt.Errorf("invalid cursor")
+	}
+
+	scheduled, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return SearchCursor{}, fmt.Errorf("invalid cursor: %w", err)
+	}
+
+	id, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return SearchCursor{}, fmt.Errorf("invalid cursor: %w", err)
+	}
+
+	return SearchCursor{Scheduled: time.Unix(0, scheduled).UTC(), SuiteName: parts[2], ID: id}, nil
+}
+
+// SearchPage selects a page of search results, Cursor points to the last run of the
+// previous page. A page without a limit contains all runs.
+type SearchPage struct {
+	Cursor SearchCursor
+	Limit  int
+}
@@ -0,0 +1,115 @@
+package model
+
+import (
+	"sort"
+)
+
+// TestFlakiness describes how flaky a test has been in the recent runs of its test suite.
+type TestFlakiness struct {
+	SuiteName string `json:"suiteName"`
+	TestName  string `json:"testName"`
+	// Runs is the number of analyzed test suite runs the test has finished in.
+	Runs int `json:"runs"`
+	// Attempts is the number of passed and failed attempts of the test.
+	Attempts int `json:"attempts"`
+	// Failures is the number of failed attempts of the test.
+	Failures int `json:"failures"`
+	// Flips is the number of times the result of an attempt differed from the result of
+	// the previous attempt, both within a run (retries) and across runs.
+	Flips int `json:"flips"`
+	// FlipRate is the share of attempts whose result differed from the previous one.
+	FlipRate float64 `json:"flipRate"`
+	// Flaky is set if the flip rate is above the configured threshold.
+	Flaky bool `json:"flaky"`
+	// Quarantined is set if the failures of the test are treated as soft failures
+	// because it is flaky.
+	Quarantined bool `json:"quarantined"`
+	// LastRunID is the id of the latest analyzed run the test has finished in.
+	LastRunID int `json:"lastRunId"`
+}
+
+// FlakinessParams configure when a test is considered flaky.
+type FlakinessParams struct {
+	// Threshold is the flip rate above which a test is flaky.
+	Threshold float64
+	// MinRuns is the number of runs a test must have finished in before it can be
+	// considered flaky.
+	MinRuns int
+}
+
+// AnalyzeFlakiness computes the flakiness of the tests of the runs of a test suite from
+// the results of their attempts. Pending runs are ignored, as are skipped attempts. The
+// returned tests are sorted by their flip rate, starting with the flakiest test.
+func AnalyzeFlakiness(runs []TestSuiteRun, params FlakinessParams) []TestFlakiness {
+	runs = append([]TestSuiteRun{}, runs...)
+
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].ID < runs[j].ID
+	})
+
+	tests := map[string]*TestFlakiness{}
+	previous := map[string]Result{}
+
+	for _, tsr := range runs {
+		if tsr.Result == ResultPending {
+			continue
+		}
+
+		attempts := append([]TestRun{}, tsr.TestResults...)
+
+		sort.SliceStable(attempts, func(i, j int) bool {
+			return attempts[i].Attempt < attempts[j].Attempt
+		})
+
+		for _, tr := range attempts {
+			if tr.Result != ResultPassed && tr.Result != ResultFailed {
+				continue
+			}
+
+			f, ok := tests[tr.Name]
+			if !ok {
+				f = &TestFlakiness{SuiteName: tsr.SuiteName, TestName: tr.Name}
+				tests[tr.Name] = f
+			}
+
+			if f.LastRunID != tsr.ID {
+				f.Runs++
+				f.LastRunID = tsr.ID
+			}
+
+			f.Attempts++
+
+			if tr.Result == ResultFailed {
+				f.Failures++
+			}
+
+			if p, ok := previous[tr.Name]; ok && p != tr.Result {
+				f.Flips++
+			}
+
+			previous[tr.Name] = tr.Result
+		}
+	}
+
+	flakiness := make([]TestFlakiness, 0, len(tests))
+
+	for _, f := range tests {
+		if f.Attempts > 1 {
+			f.FlipRate = float64(f.Flips) / float64(f.Attempts-1)
+		}
+
+		f.Flaky = f.Flips > 0 && f.Runs >= params.MinRuns && f.FlipRate >= params.Threshold
+
+		flakiness = append(flakiness, *f)
+	}
+
+	sort.Slice(flakiness, func(i, j int) bool {
+		if flakiness[i].FlipRate != flakiness[j].FlipRate {
+			return flakiness[i].FlipRate > flakiness[j].FlipRate
+		}
+
+		return flakiness[i].TestName < flakiness[j].TestName
+	})
+
+	return flakiness
+}
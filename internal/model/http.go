@@ -17,6 +17,8 @@ type TestSuiteRunHTTP struct {
 	Reference string `json:"reference"`
 	// Tests counts the total amount of tests in the suite.
 	Tests int `json:"tests"`
+	// Flaky is set to true if one or more tests only succeed after being retried.
+	Flaky bool `json:"flaky"`
 	// Scheduled is the time when the test was triggered, e.g.
 	// through a http call.
 	Scheduled time.Time `json:"scheduled"`
@@ -28,10 +30,26 @@ type TestSuiteRunHTTP struct {
 	DurationInMS int64 `json:"durationInMs"`
 	// SetupLogs are the logs that are written during the setup phase.
 	SetupLogs string `json:"setupLogs"`
+	// TeardownResult is the outcome of the teardown, empty if the suite has no teardown.
+	TeardownResult Result `json:"teardownResult,omitempty"`
+	// TeardownLogs are the logs that are written during the teardown phase.
+	TeardownLogs string `json:"teardownLogs,omitempty"`
+	// Context contains the values that were set during the setup of the run.
+	Context TestContext `json:"context,omitempty"`
 	// TriggeredBy denotes the origin of the test run, e.g. scheduled or via http call.
 	TriggeredBy string `json:"triggeredBy"`
+	// ScheduleName is the name of the schedule that started the run, if any.
+	ScheduleName string `json:"scheduleName"`
+	// ParentRunID is the id of the run whose failed tests are rerun, 0 if it is not a rerun.
+	ParentRunID int `json:"parentRunId,omitempty"`
 	// Environment is additional information on where the tests are run (e.g. cluster name).
 	Environment string `json:"environment"`
+	// QueuePosition is the 1-based position of a run that waits to be started, 0 if it is not queued.
+	QueuePosition int `json:"queuePosition"`
+	// Progress is the estimated progress of a pending run based on previous runs, if any.
+	Progress *RunProgress `json:"progress,omitempty"`
+	// Slow is set if the run took significantly longer than previous runs.
+	Slow bool `json:"slow,omitempty"`
 	// TestResults contains the detailed test results of each test.
 	TestResults []TestRunHTTP `json:"testResults"`
 }
@@ -46,6 +64,9 @@ type TestRunHTTP struct {
 	Attempt int `json:"attempt"`
 	// SoftFailure if set to true, does not fail a test suite when the test run fails.
 	SoftFailure bool `json:"softFailure"`
+	// Quarantined is set if the test was flaky when the run was started, its failures
+	// are treated as soft failures.
+	Quarantined bool `json:"quarantined,omitempty"`
 	// Logs contains log messages written by the test itself.
 	Logs string `json:"logs"`
 	// Start marks the start time of the test run.
@@ -59,4 +80,6 @@ type TestRunHTTP struct {
 	// e.g. contain correlation ids or links to external services that may help debugging a test run
 	// (among other things).
 	Context TestContext `json:"context"`
+	// Labels are the labels of the test.
+	Labels []string `json:"labels,omitempty"`
 }
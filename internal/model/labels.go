@@ -0,0 +1,245 @@
+package model
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// LabelSelector selects tests by their labels. Selectors are boolean expressions of
+// labels combined with `&&` (and), `||` (or), `!` (not) and parentheses, e.g.
+// `smoke && !slow` or `(payments || checkout) && !flaky`.
+type LabelSelector struct {
+	expr string
+	root labelExpr
+}
+
+// ParseLabelSelector parses a label selector expression.
+func ParseLabelSelector(expr string) (*LabelSelector, error) {
+	p := labelParser{tokens: tokenizeLabels(expr)}
+
+	if len(p.tokens) == 0 {
+		return nil, fmt.Errorf("empty label selector")
+	}
+
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in label selector", p.tokens[p.pos])
+	}
+
+	return &LabelSelector{expr: strings.TrimSpace(expr), root: root}, nil
+}
+
+// Matches returns true if the labels satisfy the selector, a nil selector matches
+// all labels.
+func (s *LabelSelector) Matches(labels []string) bool {
+	if s == nil {
+		return true
+	}
+
+	return s.root.matches(labels)
+}
+
+func (s *LabelSelector) String() string {
+	if s == nil {
+		return ""
+	}
+
+	return s.expr
+}
+
+func (s *LabelSelector) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *LabelSelector) UnmarshalText(text []byte) error {
+	parsed, err := ParseLabelSelector(string(text))
+	if err != nil {
+		return err
+	}
+
+	*s = *parsed
+
+	return nil
+}
+
+// ValidLabel returns true if a label can be used in a label selector.
+func ValidLabel(label string) bool {
+	return label != "" && strings.IndexFunc(label, func(r rune) bool { return !isLabelRune(r) }) == -1
+}
+
+func isLabelRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("-_./:", r)
+}
+
+type labelExpr interface {
+	matches(labels []string) bool
+}
+
+type labelMatch string
+
+func (l labelMatch) matches(labels []string) bool {
+	return slices.Contains(labels, string(l))
+}
+
+type labelNot struct{ expr labelExpr }
+
+func (n labelNot) matches(labels []string) bool {
+	return !n.expr.matches(labels)
+}
+
+type labelAnd []labelExpr
+
+func (a labelAnd) matches(labels []string) bool {
+	for _, e := range a {
+		if !e.matches(labels) {
+			return false
+		}
+	}
+
+	return true
+}
+
+type labelOr []labelExpr
+
+func (o labelOr) matches(labels []string) bool {
+	for _, e := range o {
+		if e.matches(labels) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// tokenizeLabels splits an expression into labels and the operators `&&`, `||`, `!`,
+// `(` and `)`. Invalid characters are returned as single tokens and rejected by the
+// parser.
+func tokenizeLabels(expr string) []string {
+	tokens := []string{}
+	runes := []rune(expr)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case isLabelRune(r):
+			start := i
+			for i < len(runes) && isLabelRune(runes[i]) {
+				i++
+			}
+			tokens = append(tokens, string(runes[start:i]))
+		case (r == '&' || r == '|') && i+1 < len(runes) && runes[i+1] == r:
+			tokens = append(tokens, string(runes[i:i+2]))
+			i += 2
+		default:
+			tokens = append(tokens, string(r))
+			i++
+		}
+	}
+
+	return tokens
+}
+
+// labelParser is a recursive descent parser of label selectors, `!` binds stronger than
+// `&&` which binds stronger than `||`.
+type labelParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *labelParser) next() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+
+	return p.tokens[p.pos]
+}
+
+func (p *labelParser) parseOr() (labelExpr, error) {
+	or := labelOr{}
+
+	for {
+		e, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		or = append(or, e)
+
+		if p.next() != "||" {
+			break
+		}
+		p.pos++
+	}
+
+	if len(or) == 1 {
+		return or[0], nil
+	}
+
+	return or, nil
+}
+
+func (p *labelParser) parseAnd() (labelExpr, error) {
+	and := labelAnd{}
+
+	for {
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		and = append(and, e)
+
+		if p.next() != "&&" {
+			break
+		}
+		p.pos++
+	}
+
+	if len(and) == 1 {
+		return and[0], nil
+	}
+
+	return and, nil
+}
+
+func (p *labelParser) parseUnary() (labelExpr, error) {
+	token := p.next()
+	p.pos++
+
+	switch {
+	case token == "":
+		return nil, fmt.Errorf("unexpected end of label selector")
+	case token == "!":
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		return labelNot{e}, nil
+	case token == "(":
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		if p.next() != ")" {
+			return nil, fmt.Errorf("missing closing parenthesis in label selector")
+		}
+		p.pos++
+
+		return e, nil
+	case ValidLabel(token):
+		return labelMatch(token), nil
+	default:
+		return nil, fmt.Errorf("unexpected %q in label selector", token)
+	}
+}
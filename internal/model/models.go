@@ -4,9 +4,12 @@
 package model
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"maps"
 	"regexp"
+	"slices"
 	"sort"
 	"time"
 
@@ -49,9 +52,27 @@ type TestSuiteRun struct {
 	// SetupLogs are the logs that are written during the setup phase.
 	SetupLogs string `json:"setupLogs"`
 
+	// TeardownResult is the outcome of the teardown, it is empty if the suite has
+	// no teardown or it has not run yet.
+	TeardownResult Result `json:"teardownResult,omitempty"`
+	// TeardownLogs are the logs that are written during the teardown phase.
+	TeardownLogs string `json:"teardownLogs,omitempty"`
+
+	// Context contains the values that were set on the run context during the setup,
+	// they are visible to every test of the run.
+	Context TestContext `json:"context,omitempty"`
+
 	// InitiatedBy is a reference to the initiator of this run (e.g. github web hook)
 	InitiatedBy string `json:"initiatedBy"`
 
+	// ScheduleName is the name of the schedule that started this run, it is empty
+	// for runs that were not started by a schedule.
+	ScheduleName string `json:"scheduleName,omitempty"`
+
+	// ParentRunID is the id of the run of the same suite whose failed tests are
+	// rerun by this run, it is 0 if the run is not a rerun.
+	ParentRunID int `json:"parentRunId,omitempty"`
+
 	Reference string `json:"reference"`
 
 	IdempotencyKey string `json:"idempotencyKey"`
@@ -59,39 +80,81 @@ type TestSuiteRun struct {
 	// Environment is additional information on where the tests are run (e.g. cluster name).
 	Environment string `json:"environment"`
 
+	// QueuePosition is the 1-based position of a run that waits to be started
+	// in the run queue, it is 0 if the run is not queued. It is not persisted.
+	QueuePosition int `json:"queuePosition,omitempty"`
+
+	// Progress is the estimated progress of a pending run based on the durations of
+	// previous runs. It is not persisted.
+	Progress *RunProgress `json:"progress,omitempty"`
+
+	// Slow is set if the run took significantly longer than previous runs of the
+	// test suite.
+	Slow bool `json:"slow,omitempty"`
+
 	// TestResults contains the detailed test results of each test.
 	TestResults []TestRun `json:"testResults"`
 }
 
 type ScheduledRun struct {
 	// Name is the name of the schedule.
-	Name string
+	Name string `json:"name"`
 
 	// TestSuiteName is the name of the test suite to be run.
-	TestSuiteName string
+	TestSuiteName string `json:"testSuiteName"`
 
 	// Schedule defines how often a run is scheduled. For the format see
 	// https://pkg.go.dev/github.com/robfig/cron#hdr-CRON_Expression_Format
-	Schedule string
+	Schedule string `json:"schedule"`
 
 	// TestFilter allows enabling/filtering only certain tests of a testsuite to be run
-	TestFilter *regexp.Regexp
+	TestFilter *regexp.Regexp `json:"testFilter"`
+
+	// Labels selects the tests to be run by their labels, combined with TestFilter.
+	Labels *LabelSelector `json:"labels,omitempty"`
 
 	// RunCount is the number of times a scheduled run has run in the past.
-	RunCount int
+	RunCount int `json:"runCount"`
 
 	// MaxRuns allows you to set a limit on how often a scheduled run is executed.
 	// If set to 0 it will run forever.
-	MaxRuns int
+	MaxRuns int `json:"maxRuns"`
+
+	// Paused schedules do not start new runs until they are resumed.
+	Paused bool `json:"paused"`
+
+	// Static is set for schedules that are configured when bootstrapping the
+	// server, these cannot be modified or deleted at runtime.
+	Static bool `json:"static"`
+
+	// Next is the time the schedule fires next, it is zero if the schedule is paused
+	// or has reached its maximum number of runs.
+	Next time.Time `json:"next"`
+
+	// Prev is the last time the schedule fired since the server was started.
+	Prev time.Time `json:"prev"`
+
+	// EntryID identifies the cronjob, it is 0 if the schedule is not registered.
+	EntryID cron.EntryID `json:"-"`
+}
+
+// MaxRunsReached returns true if the schedule has a run limit that is exhausted.
+func (sr ScheduledRun) MaxRunsReached() bool {
+	return sr.MaxRuns > 0 && sr.RunCount >= sr.MaxRuns
+}
 
-	// EntryID identifies the cronjob
-	EntryID cron.EntryID
+// Active returns true if the schedule starts new runs when it fires.
+func (sr ScheduledRun) Active() bool {
+	return !sr.Paused && !sr.MaxRunsReached()
 }
 
 type RunParams struct {
 	// InitiatedBy e.g. jenkins, manual user initiated, scheduled run
 	InitiatedBy string
 
+	// ScheduleName is set when the run is started by a schedule.
+	ScheduleName string
+
 	// Reference can be set when starting a new test suite run to identify
 	// a test run by a user provided value, e.g. a when started in the web ui
 	// you can add something like "manual test by user foo" to easily find this
@@ -105,14 +168,31 @@ type RunParams struct {
 	IdempotencyKey string
 
 	// Timeout defines how long a test can run before it is cancelled.
+	// If set to 0 tests can run indefinitely.
 	Timeout time.Duration
 
 	// TestFilter filters out a subset of the tests and skips the remaining ones.
 	TestFilter *regexp.Regexp
+
+	// Labels selects a subset of the tests by their labels and skips the remaining ones.
+	Labels *LabelSelector
+
+	// Tests restricts the run to the tests with these names, the remaining tests are
+	// not part of the run. If it is empty all tests are part of the run.
+	Tests []string
+
+	// ParentRunID is set when the failed tests of a previous run are rerun.
+	ParentRunID int
+
+	// Repeat runs the tests up to this many times to provoke a failure of a flaky test.
+	// Every iteration is recorded as an attempt and the run stops after the iteration in
+	// which the first test failed. Failed tests are not retried in this mode.
+	Repeat int
 }
 
 func (tsr TestSuiteRun) Copy() TestSuiteRun {
 	tsrCopy := tsr
+	tsrCopy.Context = maps.Clone(tsr.Context)
 	tsrCopy.TestResults = []TestRun{}
 
 	for _, tr := range tsr.TestResults {
@@ -123,9 +203,19 @@ func (tsr TestSuiteRun) Copy() TestSuiteRun {
 }
 
 func (t TestSuiteRun) ShouldRetry(tr TestRun) bool {
+	if t.Params.Repeat > 0 {
+		return false
+	}
+
 	return tr.Result == ResultFailed && tr.Attempt < t.Params.MaxTestAttempts
 }
 
+// ShouldRepeat returns true if a test that passed is run again because the run repeats
+// its tests until they fail.
+func (t TestSuiteRun) ShouldRepeat(tr TestRun) bool {
+	return tr.Result == ResultPassed && tr.Attempt < t.Params.Repeat
+}
+
 func (tsr TestSuiteRun) ResultFromTestResults() Result {
 	result := ResultPassed
 
@@ -156,6 +246,19 @@ func (tsr TestSuiteRun) LatestTestAttempts() []TestRun {
 	return runs
 }
 
+// FailedTests returns the names of the tests whose latest attempt failed, sorted by name.
+func (tsr TestSuiteRun) FailedTests() []string {
+	tests := []string{}
+
+	for _, tr := range tsr.LatestTestAttempts() {
+		if tr.Result == ResultFailed {
+			tests = append(tests, tr.Name)
+		}
+	}
+
+	return tests
+}
+
 func (tsr TestSuiteRun) latestTestAttempts() map[string]TestRun {
 	latestAttempts := map[string]TestRun{}
 
@@ -183,6 +286,18 @@ func (tsr TestSuiteRun) TestRunsByName(testName string) []TestRun {
 }
 
 func (tsr TestSuiteRun) IsFlaky() bool {
+	if tsr.Params.Repeat > 0 {
+		// repeated tests have more than one attempt even if they never fail, they are
+		// flaky if they failed after passing before.
+		for _, r := range tsr.TestResults {
+			if r.Result == ResultFailed && r.Attempt > 1 {
+				return true
+			}
+		}
+
+		return false
+	}
+
 	for _, r := range tsr.TestResults {
 		// todo: make sure >= 2 attempts have failed
 		// (one could have been skipped as well)
@@ -232,6 +347,10 @@ type TestRun struct {
 	// SoftFailure if set to true, does not fail a test suite when the test run fails.
 	SoftFailure bool `json:"softFailure"`
 
+	// Quarantined is set if the test was flaky when the run was started, its failures
+	// are treated as soft failures.
+	Quarantined bool `json:"quarantined,omitempty"`
+
 	// Logs contains log messages written by the test itself.
 	Logs string `json:"logs"`
 
@@ -253,6 +372,9 @@ type TestRun struct {
 	// e.g. contain correlation ids or links to external services that may help debugging a test run
 	// (among other things).
 	Context TestContext `json:"context"`
+
+	// Labels are the labels of the test.
+	Labels []string `json:"labels,omitempty"`
 }
 
 type Span struct {
@@ -303,19 +425,22 @@ func (s *Span) AddContext(key string, value any) {
 
 func (tr TestRun) Copy() TestRun {
 	trCopy := tr
-	// todo: copy values? We currently only need this during
-	// test suite run where the context is empty, this might change though.
-	trCopy.Context = make(TestContext)
+	trCopy.Context = make(TestContext, len(tr.Context))
+	maps.Copy(trCopy.Context, tr.Context)
+	trCopy.Spans = slices.Clone(tr.Spans)
+	trCopy.Labels = slices.Clone(tr.Labels)
 	return trCopy
 }
 
 func (t TestRun) NewAttempt() TestRun {
 	return TestRun{
-		SuiteName:  t.SuiteName,
-		SuiteRunID: t.SuiteRunID,
-		Name:       t.Name,
-		Result:     ResultPending,
-		Attempt:    t.Attempt + 1,
+		SuiteName:   t.SuiteName,
+		SuiteRunID:  t.SuiteRunID,
+		Name:        t.Name,
+		Result:      ResultPending,
+		Attempt:     t.Attempt + 1,
+		Labels:      t.Labels,
+		Quarantined: t.Quarantined,
 	}
 }
 
@@ -326,6 +451,9 @@ const (
 	ResultSkipped Result = "skipped"
 	ResultPassed  Result = "passed"
 	ResultFailed  Result = "failed"
+	// ResultCancelled is only used for test suite runs that were
+	// cancelled before all tests were run.
+	ResultCancelled Result = "cancelled"
 )
 
 type TestContext map[string]any
@@ -348,15 +476,82 @@ type TestSuite struct {
 	// Namespace allows grouping of test suites, e.g. by team name.
 	Namespace string
 
-	Setup func() error
+	// Setup is run before the tests of a run, values it sets on the run context are
+	// visible to all tests of the run.
+	Setup func(c *RunContext) error
 
 	// Description is used provided markdown text that describes the test suite.
 	Description string
 
-	Teardown func() error
+	// Teardown is run after the tests of a run with the values of the setup, its run
+	// context is not cancelled if the run is.
+	Teardown func(c *RunContext) error
+
+	// FailOnTeardownError fails the run if the teardown fails, otherwise the failure is
+	// only recorded in the `TeardownResult` of the run.
+	FailOnTeardownError bool
+
+	// Timeout is the maximum duration of an entire test suite run.
+	// If set to 0 the run can take indefinitely.
+	Timeout time.Duration
+
+	// TestTimeout is the default maximum duration of a single test.
+	TestTimeout time.Duration
+
+	// Parallelism is the maximum number of tests that call `t.Parallel()`
+	// that are run at the same time.
+	Parallelism int
 
 	Tests map[string]TestFunc
 	// lock      *sync.Mutex
+
+	// Labels contains the labels of the tests keyed by test name.
+	Labels map[string][]string
+
+	// External is set for test suites that are run outside of handoff and whose
+	// results are imported, their tests can not be run by handoff.
+	External bool
+}
+
+// ExternalTestSuite is the persisted definition of a test suite whose results
+// are imported, e.g. from JUnit XML reports.
+type ExternalTestSuite struct {
+	Name      string   `json:"name"`
+	Namespace string   `json:"namespace"`
+	Tests     []string `json:"tests"`
+}
+
+// TestSuite maps the external test suite to a test suite without test functions.
+func (ts ExternalTestSuite) TestSuite() TestSuite {
+	suite := TestSuite{
+		Name:      ts.Name,
+		Namespace: ts.Namespace,
+		Tests:     map[string]TestFunc{},
+		External:  true,
+	}
+
+	for _, name := range ts.Tests {
+		suite.Tests[name] = nil
+	}
+
+	return suite
+}
+
+// Namespace groups test suites, e.g. the test suites of a team.
+type Namespace struct {
+	Name   string           `json:"name"`
+	Suites []NamespaceSuite `json:"suites"`
+}
+
+// NamespaceSuite is a test suite of a namespace together with its latest run.
+type NamespaceSuite struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Tests     int    `json:"tests"`
+	External  bool   `json:"external"`
+
+	// LatestRun is nil if the test suite has not been run yet.
+	LatestRun *TestSuiteRun `json:"latestRun,omitempty"`
 }
 
 type TestSuiteWithRuns struct {
@@ -364,7 +559,7 @@ type TestSuiteWithRuns struct {
 	SuiteRuns []TestSuiteRun
 }
 
-func (t TestSuite) SafeTeardown() (err error) {
+func (t TestSuite) SafeTeardown(c *RunContext) (err error) {
 	if t.Teardown == nil {
 		return nil
 	}
@@ -377,11 +572,15 @@ func (t TestSuite) SafeTeardown() (err error) {
 		}
 	}()
 
-	err = t.Teardown()
+	err = t.Teardown(c)
+	if err == nil && c.Failed() {
+		err = ErrRunContextFailed
+	}
+
 	return
 }
 
-func (t TestSuite) SafeSetup() (err error) {
+func (t TestSuite) SafeSetup(c *RunContext) (err error) {
 	if t.Setup == nil {
 		return nil
 	}
@@ -394,16 +593,21 @@ func (t TestSuite) SafeSetup() (err error) {
 		}
 	}()
 
-	// TODO: allow setup to add values to the runtime context?
-	err = t.Setup()
+	err = t.Setup(c)
+	if err == nil && c.Failed() {
+		err = ErrRunContextFailed
+	}
+
 	return
 }
 
-func (t TestSuite) FilterTests(filter *regexp.Regexp) []string {
+// FilterTests returns the names of the tests that match both the filter and the label
+// selector, either of them can be nil.
+func (t TestSuite) FilterTests(filter *regexp.Regexp, labels *LabelSelector) []string {
 	tests := []string{}
 
 	for testName := range t.Tests {
-		if filter != nil && !filter.MatchString(testName) {
+		if !t.Selected(testName, filter, labels) {
 			continue
 		}
 		tests = append(tests, testName)
@@ -412,6 +616,11 @@ func (t TestSuite) FilterTests(filter *regexp.Regexp) []string {
 	return tests
 }
 
+// Selected returns true if a test matches both the filter and the label selector.
+func (t TestSuite) Selected(testName string, filter *regexp.Regexp, labels *LabelSelector) bool {
+	return (filter == nil || filter.MatchString(testName)) && labels.Matches(t.Labels[testName])
+}
+
 // TB is a carbon copy of the stdlib testing.TB interface + some custom handoff functions. Unfortunately we cannot reuse
 // the original testing.TB interface because it deliberately includes the `private()` function
 // to prevent others from implementing it to allow them to add new functions over time without
@@ -440,4 +649,14 @@ type TB interface {
 	SoftFailure()
 	Attempt() int
 	StartSpan(name string, kv ...any) *Span
+	// Context is cancelled when the test times out, the test suite run is
+	// cancelled or the server shuts down.
+	Context() context.Context
+	SetTimeout(timeout time.Duration)
+	Parallel()
+	// Value returns a value set by the test or by the setup of the test suite run.
+	Value(key string) any
+	// SetValue adds a value to the context of the test, e.g. a correlation id that
+	// helps debugging the test.
+	SetValue(key string, value any)
 }
@@ -0,0 +1,134 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+	"sync"
+)
+
+// ErrRunContextFailed is returned by the setup or teardown of a test suite if it was
+// marked as failed without returning an error.
+var ErrRunContextFailed = errors.New("marked as failed")
+
+// RunContext is passed to the setup and teardown of a test suite run. Values that are set
+// during the setup (e.g. the id of a tenant that was created for the run) are visible to
+// every test of the run via `T.Value()`, are persisted with the test suite run and passed
+// to the teardown and the hooks. Like `TB` it collects logs and can be marked as failed,
+// the logs are persisted as the setup or teardown logs of the run.
+type RunContext struct {
+	ctx        context.Context
+	suiteName  string
+	suiteRunID int
+
+	mu     sync.Mutex
+	values TestContext
+	logs   strings.Builder
+	failed bool
+}
+
+// NewRunContext returns the context of a test suite run, `values` are the values that
+// were set by a previous setup of the run, if there are any.
+func NewRunContext(ctx context.Context, suiteName string, suiteRunID int, values TestContext) *RunContext {
+	c := &RunContext{
+		ctx:        ctx,
+		suiteName:  suiteName,
+		suiteRunID: suiteRunID,
+		values:     TestContext{},
+	}
+
+	maps.Copy(c.values, values)
+
+	return c
+}
+
+// Context is cancelled when the test suite run times out, is cancelled or the server
+// shuts down.
+func (c *RunContext) Context() context.Context {
+	return c.ctx
+}
+
+func (c *RunContext) SuiteName() string {
+	return c.suiteName
+}
+
+func (c *RunContext) SuiteRunID() int {
+	return c.suiteRunID
+}
+
+func (c *RunContext) Value(key string) any {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.values[key]
+}
+
+// SetValue sets a value that is visible to every test of the run, values have to be
+// json serializable to be persisted.
+func (c *RunContext) SetValue(key string, value any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.values[key] = value
+}
+
+// Values returns a copy of all values of the run.
+func (c *RunContext) Values() TestContext {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return maps.Clone(c.values)
+}
+
+func (c *RunContext) Log(args ...any) {
+	c.writeLog(fmt.Sprint(args...))
+}
+
+func (c *RunContext) Logf(format string, args ...any) {
+	c.writeLog(fmt.Sprintf(format, args...))
+}
+
+func (c *RunContext) writeLog(line string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.logs.WriteString(line + "\n")
+}
+
+// Error logs the args and marks the setup or teardown as failed.
+func (c *RunContext) Error(args ...any) {
+	c.Log(args...)
+	c.Fail()
+}
+
+// Errorf logs the formatted message and marks the setup or teardown as failed.
+func (c *RunContext) Errorf(format string, args ...any) {
+	c.Logf(format, args...)
+	c.Fail()
+}
+
+// Fail marks the setup or teardown as failed, it fails the same way as returning an
+// error but lets the function continue.
+func (c *RunContext) Fail() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.failed = true
+}
+
+func (c *RunContext) Failed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.failed
+}
+
+// Logs returns everything that was logged so far.
+func (c *RunContext) Logs() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.logs.String()
+}
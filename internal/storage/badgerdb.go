@@ -3,10 +3,9 @@ package storage
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
-	"strconv"
-	"strings"
 	"sync"
 	"time"
 
@@ -54,6 +53,11 @@ func NewBadgerStorage(dbPath string,
 		sequences: make(map[string]*badger.Sequence),
 	}
 
+	if err := s.buildRunIndexes(); err != nil {
+		badgerDB.Close()
+		return nil, fmt.Errorf("indexing test suite runs: %w", err)
+	}
+
 	return s, nil
 }
 
@@ -97,10 +101,6 @@ func (b *BadgerStorage) RollbackTransaction(ctx context.Context) {
 	getTx(ctx).Discard()
 }
 
-func testSuiteRunKey(suiteName string, id int) []byte {
-	return []byte(fmt.Sprintf("suite-%s-%v", suiteName, id))
-}
-
 func (b *BadgerStorage) getSequence(key []byte) (*badger.Sequence, error) {
 	b.lock.Lock()
 	seq, found := b.sequences[string(key)]
@@ -167,7 +167,7 @@ func (b *BadgerStorage) InsertTestSuiteRun(ctx context.Context, tsr model.TestSu
 			return fmt.Errorf("add pending key: %w", err)
 		}
 
-		return nil
+		return setRunIndexes(t, nil, tsr, 0)
 	})
 
 	return id, err
@@ -183,6 +183,11 @@ func (b *BadgerStorage) UpdateTestSuiteRun(ctx context.Context, tsr model.TestSu
 		key := testSuiteRunKey(tsr.SuiteName, tsr.ID)
 		pendingKey := append([]byte("pending-"), key...)
 
+		var previous [][]byte
+		if old, err := loadTestSuiteRun(t, key); err == nil {
+			previous = runIndexKeys(old)
+		}
+
 		e := badger.NewEntry(key, data)
 
 		if tsr.Result != model.ResultPending {
@@ -201,7 +206,7 @@ func (b *BadgerStorage) UpdateTestSuiteRun(ctx context.Context, tsr model.TestSu
 			return fmt.Errorf("inserting test suite run: %w", err)
 		}
 
-		return nil
+		return setRunIndexes(t, previous, tsr, e.ExpiresAt)
 	})
 
 	return err
@@ -211,16 +216,9 @@ func (b *BadgerStorage) LoadTestSuiteRunByKey(
 	ctx context.Context,
 	key string,
 ) (model.TestSuiteRun, error) {
-	parts := strings.Split(key, "-")
-
-	if len(parts) < 3 {
-		return model.TestSuiteRun{}, fmt.Errorf("invalid testsuite run key: %s", key)
-	}
-
-	suiteName := strings.Join(parts[1:len(parts)-1], "-")
-	runID, err := strconv.Atoi(parts[len(parts)-1])
+	suiteName, runID, err := parseTestSuiteRunKey(key)
 	if err != nil {
-		return model.TestSuiteRun{}, fmt.Errorf("id part of the key %s it not an int", key)
+		return model.TestSuiteRun{}, err
 	}
 
 	return b.LoadTestSuiteRun(ctx, suiteName, runID)
@@ -242,7 +240,9 @@ func loadTestSuiteRun(txn *badger.Txn, key []byte) (model.TestSuiteRun, error) {
 	var tsr model.TestSuiteRun
 
 	item, err := txn.Get(key)
-	if err != nil {
+	if err == badger.ErrKeyNotFound {
+		return tsr, model.NotFoundError{}
+	} else if err != nil {
 		return tsr, fmt.Errorf("loading test suite run: %w", err)
 	}
 
@@ -297,30 +297,106 @@ func (b *BadgerStorage) LoadTestSuiteRunsByName(ctx context.Context, suiteName s
 	runs := []model.TestSuiteRun{}
 
 	err := b.runTx(ctx, false, func(txn *badger.Txn) error {
-		it := txn.NewIterator(badger.DefaultIteratorOptions)
-		defer it.Close()
+		return iterateRunIndex(txn, runIndexAll, suiteName, "", false, 0, func(id int) (bool, error) {
+			tsr, err := loadTestSuiteRun(txn, testSuiteRunKey(suiteName, id))
+			if errors.Is(err, model.NotFoundError{}) {
+				return true, nil
+			} else if err != nil {
+				return false, err
+			}
 
-		prefix := []byte("suite-" + suiteName + "-")
+			runs = append(runs, tsr)
 
-		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
-			item := it.Item()
-			var tsr model.TestSuiteRun
+			return true, nil
+		})
+	})
 
-			err := item.Value(func(v []byte) error {
-				return json.Unmarshal(v, &tsr)
-			})
+	return runs, err
+}
 
-			if err != nil {
-				return fmt.Errorf("unmarshaling test suite run: %w", err)
+// ListTestSuiteRuns looks up the runs with the index of the most selective field of the
+// filter, the remaining conditions are checked after loading the runs.
+func (b *BadgerStorage) ListTestSuiteRuns(
+	ctx context.Context,
+	suiteName string,
+	filter model.TestSuiteRunFilter,
+	page model.Page,
+) ([]model.TestSuiteRun, int, error) {
+	runs := []model.TestSuiteRun{}
+	next := 0
+
+	field, value := filterIndexValue(filter)
+
+	err := b.runTx(ctx, false, func(txn *badger.Txn) error {
+		return iterateRunIndex(txn, field, suiteName, value, true, page.Cursor, func(id int) (bool, error) {
+			tsr, err := loadTestSuiteRun(txn, testSuiteRunKey(suiteName, id))
+			if errors.Is(err, model.NotFoundError{}) {
+				return true, nil
+			} else if err != nil {
+				return false, err
+			}
+
+			if !filter.Matches(tsr) {
+				return true, nil
+			}
+
+			if page.Limit > 0 && len(runs) == page.Limit {
+				next = runs[len(runs)-1].ID
+				return false, nil
 			}
 
 			runs = append(runs, tsr)
-		}
 
-		return nil
+			return true, nil
+		})
 	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("listing test suite runs: %w", err)
+	}
 
-	return runs, err
+	return runs, next, nil
+}
+
+// SearchTestSuiteRuns looks up the runs with the search index of the most selective field
+// of the filter, the remaining conditions are checked after loading the runs.
+func (b *BadgerStorage) SearchTestSuiteRuns(
+	ctx context.Context,
+	filter model.TestSuiteRunFilter,
+	page model.SearchPage,
+) ([]model.TestSuiteRun, model.SearchCursor, error) {
+	runs := []model.TestSuiteRun{}
+	next := model.SearchCursor{}
+
+	field, value := searchFilterIndexValue(filter)
+
+	err := b.runTx(ctx, false, func(txn *badger.Txn) error {
+		return iterateSearchIndex(txn, field, value, page.Cursor, func(suiteName string, id int) (bool, error) {
+			tsr, err := loadTestSuiteRun(txn, testSuiteRunKey(suiteName, id))
+			if errors.Is(err, model.NotFoundError{}) {
+				return true, nil
+			} else if err != nil {
+				return false, err
+			}
+
+			if !filter.Matches(tsr) {
+				return true, nil
+			}
+
+			if page.Limit > 0 && len(runs) == page.Limit {
+				next = model.SearchCursorOf(runs[len(runs)-1])
+				return false, nil
+			}
+
+			runs = append(runs, tsr)
+
+			return true, nil
+		})
+	})
+	if err != nil {
+		return nil, model.SearchCursor{}, fmt.Errorf("searching test suite runs: %w", err)
+	}
+
+	return runs, next, nil
 }
 
 func scheduledRunKey(scheduleName string) []byte {
@@ -348,6 +424,57 @@ func (b *BadgerStorage) InsertScheduledRun(ctx context.Context, sr model.Schedul
 	return err
 }
 
+func (b *BadgerStorage) UpdateScheduledRun(ctx context.Context, sr model.ScheduledRun) error {
+	err := b.runTx(ctx, true, func(t *badger.Txn) error {
+		if _, err := t.Get(scheduledRunKey(sr.Name)); err == badger.ErrKeyNotFound {
+			return model.NotFoundError{}
+		} else if err != nil {
+			return err
+		}
+
+		data, err := json.Marshal(sr)
+		if err != nil {
+			return fmt.Errorf("marshalling scheduled run: %w", err)
+		}
+
+		return t.Set(scheduledRunKey(sr.Name), data)
+	})
+
+	if err != nil {
+		return fmt.Errorf("updating scheduled run: %w", err)
+	}
+
+	return nil
+}
+
+func (b *BadgerStorage) LoadScheduledRuns(ctx context.Context) ([]model.ScheduledRun, error) {
+	schedules := []model.ScheduledRun{}
+
+	err := b.runTx(ctx, false, func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		prefix := scheduledRunKey("")
+
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			var sr model.ScheduledRun
+
+			err := it.Item().Value(func(v []byte) error {
+				return json.Unmarshal(v, &sr)
+			})
+			if err != nil {
+				return fmt.Errorf("unmarshaling scheduled run: %w", err)
+			}
+
+			schedules = append(schedules, sr)
+		}
+
+		return nil
+	})
+
+	return schedules, err
+}
+
 func (b *BadgerStorage) DeleteScheduledRun(ctx context.Context, name string) error {
 	err := b.runTx(ctx, true, func(t *badger.Txn) error {
 		_, err := t.Get(scheduledRunKey(name))
@@ -367,6 +494,55 @@ func (b *BadgerStorage) DeleteScheduledRun(ctx context.Context, name string) err
 	return nil
 }
 
+func externalTestSuiteKey(suiteName string) []byte {
+	return []byte("external-suite-" + suiteName)
+}
+
+func (b *BadgerStorage) SaveExternalTestSuite(ctx context.Context, ts model.ExternalTestSuite) error {
+	err := b.runTx(ctx, true, func(t *badger.Txn) error {
+		data, err := json.Marshal(ts)
+		if err != nil {
+			return fmt.Errorf("marshalling external test suite: %w", err)
+		}
+
+		return t.Set(externalTestSuiteKey(ts.Name), data)
+	})
+
+	if err != nil {
+		return fmt.Errorf("saving external test suite: %w", err)
+	}
+
+	return nil
+}
+
+func (b *BadgerStorage) LoadExternalTestSuites(ctx context.Context) ([]model.ExternalTestSuite, error) {
+	suites := []model.ExternalTestSuite{}
+
+	err := b.runTx(ctx, false, func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		prefix := externalTestSuiteKey("")
+
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			var ts model.ExternalTestSuite
+
+			err := it.Item().Value(func(v []byte) error {
+				return json.Unmarshal(v, &ts)
+			})
+			if err != nil {
+				return fmt.Errorf("unmarshaling external test suite: %w", err)
+			}
+
+			suites = append(suites, ts)
+		}
+
+		return nil
+	})
+
+	return suites, err
+}
+
 func idempotencyKey(key string) []byte {
 	return []byte("idempotent-" + key)
 }
@@ -0,0 +1,355 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/raphi011/handoff/internal/model"
+)
+
+// Test suite runs are indexed by the fields that they can be filtered by. An index key
+// consists of the indexed field, the suite name, the value of the field and the run id:
+//
+//	idx-run-<field>\x00<suite-name>\x00<value>\x00<big endian run id>
+//
+// The big endian run id makes sure that the keys of a suite are sorted by their id. The
+// `all` index contains every run and is used if no indexed field is filtered by.
+const runIndexPrefix = "idx-run-"
+
+// The runs of all suites are additionally indexed for searches across test suites. These
+// keys are sorted by the time the runs were scheduled at, their suite name and id:
+//
+//	idx-search-<field>\x00<value>\x00<big endian scheduled unix nanos><suite-name>\x00<big endian run id>
+const searchIndexPrefix = "idx-search-"
+
+// runIndexVersion is increased whenever the indexes change, the indexes of existing
+// runs are rebuilt on startup if the persisted version is outdated.
+const runIndexVersion = "2"
+
+var runIndexVersionKey = []byte("idx-run-version")
+
+const runIndexAll = "all"
+
+// runIndexFields are the indexed fields, ordered by their selectivity.
+var runIndexFields = []string{"schedule", "reference", "initiated-by", "result", "environment", "flaky"}
+
+// runIndexValues returns the values of all indexed fields of a run, fields that are
+// not set are not indexed.
+func runIndexValues(tsr model.TestSuiteRun) map[string]string {
+	values := map[string]string{
+		runIndexAll:    "",
+		"schedule":     tsr.ScheduleName,
+		"reference":    tsr.Reference,
+		"initiated-by": tsr.InitiatedBy,
+		"result":       string(tsr.Result),
+		"environment":  tsr.Environment,
+		"flaky":        strconv.FormatBool(tsr.Flaky),
+	}
+
+	for field, value := range values {
+		if field != runIndexAll && value == "" {
+			delete(values, field)
+		}
+	}
+
+	return values
+}
+
+// filterIndexValues returns the indexed field and value that is used to look up runs
+// that match a filter.
+func filterIndexValue(filter model.TestSuiteRunFilter) (string, string) {
+	flaky := ""
+	if filter.Flaky != nil {
+		flaky = strconv.FormatBool(*filter.Flaky)
+	}
+
+	values := map[string]string{
+		"schedule":     filter.ScheduleName,
+		"reference":    filter.Reference,
+		"initiated-by": filter.InitiatedBy,
+		"result":       string(filter.Result),
+		"environment":  filter.Environment,
+		"flaky":        flaky,
+	}
+
+	for _, field := range runIndexFields {
+		if v := values[field]; v != "" {
+			return field, v
+		}
+	}
+
+	return runIndexAll, ""
+}
+
+// searchIndexFields are the fields that are indexed for searches, ordered by their
+// selectivity.
+var searchIndexFields = []string{"idempotency-key", "reference", "initiated-by", "environment", "result"}
+
+func searchIndexValues(tsr model.TestSuiteRun) map[string]string {
+	values := map[string]string{
+		runIndexAll:       "",
+		"idempotency-key": tsr.IdempotencyKey,
+		"reference":       tsr.Reference,
+		"initiated-by":    tsr.InitiatedBy,
+		"environment":     tsr.Environment,
+		"result":          string(tsr.Result),
+	}
+
+	for field, value := range values {
+		if field != runIndexAll && value == "" {
+			delete(values, field)
+		}
+	}
+
+	return values
+}
+
+// searchFilterIndexValue returns the search index field and value that is used to look
+// up runs that match a filter.
+func searchFilterIndexValue(filter model.TestSuiteRunFilter) (string, string) {
+	values := map[string]string{
+		"idempotency-key": filter.IdempotencyKey,
+		"reference":       filter.Reference,
+		"initiated-by":    filter.InitiatedBy,
+		"environment":     filter.Environment,
+		"result":          string(filter.Result),
+	}
+
+	for _, field := range searchIndexFields {
+		if v := values[field]; v != "" {
+			return field, v
+		}
+	}
+
+	return runIndexAll, ""
+}
+
+func searchIndexKeyPrefix(field, value string) []byte {
+	return []byte(searchIndexPrefix + field + "\x00" + value + "\x00")
+}
+
+// searchIndexPosition returns the part of a search index key that follows the prefix.
+func searchIndexPosition(c model.SearchCursor) []byte {
+	pos := binary.BigEndian.AppendUint64(nil, uint64(c.Scheduled.UnixNano()))
+	pos = append(pos, c.SuiteName+"\x00"...)
+
+	return binary.BigEndian.AppendUint64(pos, uint64(c.ID))
+}
+
+func searchIndexKey(field, value string, tsr model.TestSuiteRun) []byte {
+	return append(searchIndexKeyPrefix(field, value), searchIndexPosition(model.SearchCursorOf(tsr))...)
+}
+
+// parseSearchIndexKey returns the suite name and run id of a search index key.
+func parseSearchIndexKey(prefix, key []byte) (string, int) {
+	return string(key[len(prefix)+8 : len(key)-9]), runIndexID(key)
+}
+
+// runIndexKeys returns the keys of all index entries of a run.
+func runIndexKeys(tsr model.TestSuiteRun) [][]byte {
+	keys := [][]byte{}
+
+	for field, value := range runIndexValues(tsr) {
+		keys = append(keys, runIndexKey(field, tsr.SuiteName, value, tsr.ID))
+	}
+
+	for field, value := range searchIndexValues(tsr) {
+		keys = append(keys, searchIndexKey(field, value, tsr))
+	}
+
+	return keys
+}
+
+func runIndexKeyPrefix(field, suiteName, value string) []byte {
+	return []byte(runIndexPrefix + field + "\x00" + suiteName + "\x00" + value + "\x00")
+}
+
+func runIndexKey(field, suiteName, value string, id int) []byte {
+	return binary.BigEndian.AppendUint64(runIndexKeyPrefix(field, suiteName, value), uint64(id))
+}
+
+func runIndexID(key []byte) int {
+	return int(binary.BigEndian.Uint64(key[len(key)-8:]))
+}
+
+// setRunIndexes replaces the index entries of a run, `previous` contains the index keys
+// of the run before the update, if there are any.
+func setRunIndexes(txn *badger.Txn, previous [][]byte, tsr model.TestSuiteRun, expiresAt uint64) error {
+	for _, key := range previous {
+		if err := txn.Delete(key); err != nil {
+			return fmt.Errorf("deleting index: %w", err)
+		}
+	}
+
+	for _, key := range runIndexKeys(tsr) {
+		e := badger.NewEntry(key, nil)
+		e.ExpiresAt = expiresAt
+
+		if err := txn.SetEntry(e); err != nil {
+			return fmt.Errorf("setting index: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// iterateRunIndex calls `f` with the ids of the runs of an index. If `reverse` is set the
+// ids are iterated from the newest to the oldest starting before `cursor` (if it is set).
+// Iteration stops if `f` returns false or an error.
+func iterateRunIndex(
+	txn *badger.Txn,
+	field, suiteName, value string,
+	reverse bool,
+	cursor int,
+	f func(id int) (bool, error),
+) error {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	opts.Reverse = reverse
+
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	prefix := runIndexKeyPrefix(field, suiteName, value)
+
+	seek := prefix
+	if reverse {
+		start := ^uint64(0)
+		if cursor > 0 {
+			start = uint64(cursor - 1)
+		}
+
+		seek = binary.BigEndian.AppendUint64(bytes.Clone(prefix), start)
+	}
+
+	for it.Seek(seek); it.ValidForPrefix(prefix); it.Next() {
+		next, err := f(runIndexID(it.Item().Key()))
+		if err != nil || !next {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// iterateSearchIndex calls `f` with the suite names and ids of the runs of a search index
+// from the newest to the oldest run, starting after `cursor` (if it is set). Iteration
+// stops if `f` returns false or an error.
+func iterateSearchIndex(
+	txn *badger.Txn,
+	field, value string,
+	cursor model.SearchCursor,
+	f func(suiteName string, id int) (bool, error),
+) error {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	opts.Reverse = true
+
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	prefix := searchIndexKeyPrefix(field, value)
+
+	seek := append(bytes.Clone(prefix), 0xff)
+	if !cursor.IsZero() {
+		seek = append(bytes.Clone(prefix), searchIndexPosition(cursor)...)
+	}
+
+	for it.Seek(seek); it.ValidForPrefix(prefix); it.Next() {
+		key := it.Item().Key()
+		if bytes.Equal(key, seek) {
+			// the cursor points to the last run of the previous page.
+			continue
+		}
+
+		next, err := f(parseSearchIndexKey(prefix, key))
+		if err != nil || !next {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// buildRunIndexes indexes all runs that were persisted before the current index
+// version was introduced.
+func (b *BadgerStorage) buildRunIndexes() error {
+	upToDate := false
+
+	err := b.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(runIndexVersionKey)
+		if err == badger.ErrKeyNotFound {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		return item.Value(func(v []byte) error {
+			upToDate = string(v) == runIndexVersion
+			return nil
+		})
+	})
+	if err != nil || upToDate {
+		return err
+	}
+
+	start := time.Now()
+	indexed := 0
+
+	wb := b.db.NewWriteBatch()
+	defer wb.Cancel()
+
+	err = b.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		prefix := []byte("suite-")
+
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+
+			var tsr model.TestSuiteRun
+
+			err := item.Value(func(v []byte) error {
+				return json.Unmarshal(v, &tsr)
+			})
+			if err != nil || tsr.SuiteName == "" {
+				// e.g. the sequence of a test suite whose name starts with `suite-`.
+				continue
+			}
+
+			for _, key := range runIndexKeys(tsr) {
+				e := badger.NewEntry(key, nil)
+				e.ExpiresAt = item.ExpiresAt()
+
+				if err := wb.SetEntry(e); err != nil {
+					return err
+				}
+			}
+
+			indexed++
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := wb.Set(runIndexVersionKey, []byte(runIndexVersion)); err != nil {
+		return err
+	}
+
+	if err := wb.Flush(); err != nil {
+		return err
+	}
+
+	b.log.Info("Indexed test suite runs", "runs", indexed, "duration", time.Since(start))
+
+	return nil
+}
@@ -1,7 +1,6 @@
 package storage_test
 
 import (
-	"context"
 	"fmt"
 	"log"
 	"log/slog"
@@ -10,83 +9,17 @@ import (
 	"time"
 
 	"github.com/dgraph-io/badger/v4"
-	"github.com/raphi011/handoff/internal/model"
 	"github.com/raphi011/handoff/internal/storage"
 	"github.com/stretchr/testify/assert"
 )
 
-func TestBadgerdb(t *testing.T) {
-	db, err := storage.NewBadgerStorage("", 0, nil, slog.Default())
-	assert.NoError(t, err)
-
-	ctx, err := db.StartTransaction(context.Background())
-	assert.NoError(t, err)
-
-	suiteName := "test-suite"
-
-	tr := model.TestRun{
-		SuiteName: suiteName,
-		Attempt:   1,
-		Name:      "Bla",
-	}
-
-	id, err := db.InsertTestSuiteRun(ctx, model.TestSuiteRun{
-		SuiteName:   suiteName,
-		TestResults: []model.TestRun{tr},
-	})
-	assert.NoError(t, err)
-	assert.Equal(t, 1, id)
+func TestBadgerStorage(t *testing.T) {
+	testStorage(t, func(t *testing.T) storage.Storage {
+		db, err := storage.NewBadgerStorage("", 0, nil, slog.Default())
+		assert.NoError(t, err)
 
-	id, err = db.InsertTestSuiteRun(ctx, model.TestSuiteRun{
-		SuiteName: suiteName,
+		return db
 	})
-	assert.NoError(t, err)
-	assert.Equal(t, 2, id)
-
-	_, err = db.LoadTestSuiteRun(ctx, suiteName, 1)
-	assert.NoError(t, err)
-
-	_, err = db.LoadTestSuiteRunsByName(ctx, suiteName)
-	assert.NoError(t, err)
-}
-
-func TestDeleteScheduledRun(t *testing.T) {
-	db, err := storage.NewBadgerStorage("", 0, nil, slog.Default())
-	assert.NoError(t, err)
-
-	ctx := context.Background()
-
-	assert.NoError(t, db.InsertScheduledRun(ctx, model.ScheduledRun{
-		Name:          "sr",
-		TestSuiteName: "sn",
-	}))
-
-	err = db.DeleteScheduledRun(ctx, "sr")
-	assert.NoError(t, err)
-}
-
-func TestIdempotencyKey(t *testing.T) {
-	db, err := storage.NewBadgerStorage("", 0, nil, slog.Default())
-	assert.NoError(t, err)
-
-	ctx := context.Background()
-
-	tsrID, err := db.InsertIdempotencyKey(ctx, "foo")
-	assert.NoError(t, err)
-	assert.Empty(t, tsrID)
-
-	var duplicateErr model.DuplicateError
-
-	tsrID, err = db.InsertIdempotencyKey(ctx, "foo")
-	assert.ErrorAs(t, err, &duplicateErr)
-	assert.Empty(t, tsrID)
-
-	err = db.UpdateIdempotencyKey(ctx, "foo", "my-app", 1)
-	assert.NoError(t, err)
-
-	tsrID, err = db.InsertIdempotencyKey(ctx, "foo")
-	assert.ErrorAs(t, err, &duplicateErr)
-	assert.Equal(t, tsrID, "suite-my-app-1")
 }
 
 func TestTransaction(t *testing.T) {
@@ -0,0 +1,505 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/raphi011/handoff/internal/model"
+	"github.com/robfig/cron/v3"
+)
+
+// postgresDrivers are the names of the `database/sql` drivers that can connect to PostgreSQL.
+// Handoff does not import a driver itself, the binary that runs handoff has to register
+// one, e.g. by importing `github.com/jackc/pgx/v5/stdlib` or `github.com/lib/pq`.
+var postgresDrivers = []string{"pgx", "postgres"}
+
+// postgresMigrationLock is the id of the advisory lock that is held while migrating, so
+// that multiple instances that start at the same time do not migrate concurrently.
+const postgresMigrationLock = 7_291_874_301
+
+// postgresMigrations contains the statements that create and update the schema. Statements
+// are run in order and must never be changed once released, add new ones instead.
+var postgresMigrations = []string{
+	`CREATE TABLE test_suite_run_sequences (
+		suite_name TEXT PRIMARY KEY,
+		last_id    INTEGER NOT NULL
+	)`,
+	`CREATE TABLE test_suite_runs (
+		suite_name    TEXT NOT NULL,
+		id            INTEGER NOT NULL,
+		result        TEXT NOT NULL,
+		pending       BOOLEAN NOT NULL,
+		environment   TEXT NOT NULL,
+		reference     TEXT NOT NULL,
+		initiated_by  TEXT NOT NULL,
+		schedule_name TEXT NOT NULL,
+		flaky         BOOLEAN NOT NULL,
+		scheduled_at  TIMESTAMPTZ,
+		data          JSONB NOT NULL,
+		expires_at    TIMESTAMPTZ,
+		PRIMARY KEY (suite_name, id)
+	)`,
+	`CREATE INDEX test_suite_runs_pending_idx ON test_suite_runs (suite_name, id) WHERE pending`,
+	`CREATE INDEX test_suite_runs_result_idx ON test_suite_runs (suite_name, result, id)`,
+	`CREATE INDEX test_suite_runs_reference_idx ON test_suite_runs (suite_name, reference, id)`,
+	`CREATE INDEX test_suite_runs_schedule_name_idx ON test_suite_runs (schedule_name, id)`,
+	`CREATE INDEX test_suite_runs_scheduled_at_idx ON test_suite_runs (suite_name, scheduled_at)`,
+	`CREATE INDEX test_suite_runs_search_idx ON test_suite_runs (scheduled_at, suite_name, id)`,
+	`CREATE INDEX test_suite_runs_global_reference_idx ON test_suite_runs (reference)`,
+	`CREATE TABLE scheduled_runs (
+		name TEXT PRIMARY KEY,
+		data JSONB NOT NULL
+	)`,
+	`CREATE TABLE external_test_suites (
+		name TEXT PRIMARY KEY,
+		data JSONB NOT NULL
+	)`,
+	`CREATE TABLE idempotency_keys (
+		key        TEXT PRIMARY KEY,
+		run_key    TEXT,
+		expires_at TIMESTAMPTZ
+	)`,
+}
+
+type PostgresStorage struct {
+	db  *sql.DB
+	log *slog.Logger
+	ttl time.Duration
+}
+
+// NewPostgresStorage connects to a PostgreSQL database and migrates its schema.
+func NewPostgresStorage(url string,
+	ttl time.Duration,
+	gcCron *cron.Cron,
+	log *slog.Logger,
+) (*PostgresStorage, error) {
+	driver, err := sqlDriver("postgres", postgresDrivers)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open(driver, url)
+	if err != nil {
+		return nil, fmt.Errorf("opening postgres database: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("connecting to postgres database: %w", err)
+	}
+
+	s := &PostgresStorage{
+		db:  db,
+		log: log,
+		ttl: ttl,
+	}
+
+	if err := s.migrate(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrating postgres database: %w", err)
+	}
+
+	if gcCron != nil {
+		_, err = gcCron.AddFunc("@every 5m", func() {
+			if err := s.deleteExpired(context.Background()); err != nil {
+				log.Warn("postgres gc", "error", err)
+			}
+		})
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// migrate runs all migrations that have not been run yet.
+func (p *PostgresStorage) migrate(ctx context.Context) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, postgresMigrationLock); err != nil {
+		return fmt.Errorf("acquiring migration lock: %w", err)
+	}
+
+	if err := migrate(ctx, tx, postgresMigrations); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// deleteExpired removes the test suite runs and idempotency keys that have expired.
+func (p *PostgresStorage) deleteExpired(ctx context.Context) error {
+	if _, err := p.db.ExecContext(ctx, `DELETE FROM test_suite_runs WHERE expires_at < now()`); err != nil {
+		return fmt.Errorf("deleting expired test suite runs: %w", err)
+	}
+
+	if _, err := p.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at < now()`); err != nil {
+		return fmt.Errorf("deleting expired idempotency keys: %w", err)
+	}
+
+	return nil
+}
+
+func (p *PostgresStorage) Close() error {
+	return p.db.Close()
+}
+
+func (p *PostgresStorage) StartTransaction(ctx context.Context) (context.Context, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return ctx, err
+	}
+
+	return context.WithValue(ctx, SQLStorageContextKey("transaction"), tx), nil
+}
+
+// q returns the transaction of the context or the database if there is none.
+func (p *PostgresStorage) q(ctx context.Context) querier {
+	if tx := getSQLTx(ctx); tx != nil {
+		return tx
+	}
+
+	return p.db
+}
+
+func (p *PostgresStorage) CommitTransaction(ctx context.Context) error {
+	return getSQLTx(ctx).Commit()
+}
+
+func (p *PostgresStorage) RollbackTransaction(ctx context.Context) {
+	_ = getSQLTx(ctx).Rollback()
+}
+
+func (p *PostgresStorage) InsertTestSuiteRun(ctx context.Context, tsr model.TestSuiteRun) (int, error) {
+	var id int
+
+	err := p.q(ctx).QueryRowContext(ctx, `
+		INSERT INTO test_suite_run_sequences (suite_name, last_id) VALUES ($1, 1)
+		ON CONFLICT (suite_name) DO UPDATE SET last_id = test_suite_run_sequences.last_id + 1
+		RETURNING last_id`, tsr.SuiteName).Scan(&id)
+	if err != nil {
+		return -1, fmt.Errorf("unable to get next sequence: %w", err)
+	}
+
+	tsr.ID = id
+
+	data, err := json.Marshal(tsr)
+	if err != nil {
+		return -1, fmt.Errorf("marshalling test suite run: %w", err)
+	}
+
+	_, err = p.q(ctx).ExecContext(ctx, `
+		INSERT INTO test_suite_runs (
+			suite_name, id, pending, data, result, environment, initiated_by,
+			reference, schedule_name, flaky, scheduled_at
+		) VALUES ($1, $2, TRUE, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		append([]any{tsr.SuiteName, id, string(data)}, postgresRunColumns(tsr)...)...)
+	if err != nil {
+		return -1, fmt.Errorf("inserting test suite run: %w", err)
+	}
+
+	return id, nil
+}
+
+func (p *PostgresStorage) UpdateTestSuiteRun(ctx context.Context, tsr model.TestSuiteRun) error {
+	data, err := json.Marshal(tsr)
+	if err != nil {
+		return fmt.Errorf("marshalling test suite run: %w", err)
+	}
+
+	pending := tsr.Result == model.ResultPending
+
+	var expiresAt *time.Time
+	if !pending && p.ttl > 0 {
+		t := time.Now().Add(p.ttl)
+		expiresAt = &t
+	}
+
+	_, err = p.q(ctx).ExecContext(ctx, `
+		UPDATE test_suite_runs SET
+			pending = $3, data = $4, expires_at = $5, result = $6, environment = $7,
+			initiated_by = $8, reference = $9, schedule_name = $10, flaky = $11, scheduled_at = $12
+		WHERE suite_name = $1 AND id = $2`,
+		append([]any{tsr.SuiteName, tsr.ID, pending, string(data), expiresAt}, postgresRunColumns(tsr)...)...)
+	if err != nil {
+		return fmt.Errorf("updating test suite run: %w", err)
+	}
+
+	return nil
+}
+
+// postgresRunColumns returns the values of the columns that runs can be filtered by.
+func postgresRunColumns(tsr model.TestSuiteRun) []any {
+	var scheduled *time.Time
+	if !tsr.Scheduled.IsZero() {
+		scheduled = &tsr.Scheduled
+	}
+
+	return []any{
+		string(tsr.Result), tsr.Environment, tsr.InitiatedBy,
+		tsr.Reference, tsr.ScheduleName, tsr.Flaky, scheduled,
+	}
+}
+
+func (p *PostgresStorage) LoadTestSuiteRunByKey(ctx context.Context, key string) (model.TestSuiteRun, error) {
+	suiteName, runID, err := parseTestSuiteRunKey(key)
+	if err != nil {
+		return model.TestSuiteRun{}, err
+	}
+
+	return p.LoadTestSuiteRun(ctx, suiteName, runID)
+}
+
+func (p *PostgresStorage) LoadTestSuiteRun(ctx context.Context, suiteName string, runID int) (model.TestSuiteRun, error) {
+	var data []byte
+
+	err := p.q(ctx).QueryRowContext(ctx, `
+		SELECT data FROM test_suite_runs
+		WHERE suite_name = $1 AND id = $2 AND (expires_at IS NULL OR expires_at > now())`,
+		suiteName, runID).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.TestSuiteRun{}, model.NotFoundError{}
+	} else if err != nil {
+		return model.TestSuiteRun{}, fmt.Errorf("loading test suite run: %w", err)
+	}
+
+	var tsr model.TestSuiteRun
+
+	if err := json.Unmarshal(data, &tsr); err != nil {
+		return model.TestSuiteRun{}, fmt.Errorf("unmarshaling test suite run: %w", err)
+	}
+
+	return tsr, nil
+}
+
+func (p *PostgresStorage) LoadPendingTestSuiteRuns(ctx context.Context) ([]model.TestSuiteRun, error) {
+	return p.loadTestSuiteRuns(ctx, `
+		SELECT data FROM test_suite_runs WHERE pending
+		ORDER BY suite_name, id`)
+}
+
+func (p *PostgresStorage) LoadTestSuiteRunsByName(ctx context.Context, suiteName string) ([]model.TestSuiteRun, error) {
+	return p.loadTestSuiteRuns(ctx, `
+		SELECT data FROM test_suite_runs
+		WHERE suite_name = $1 AND (expires_at IS NULL OR expires_at > now())
+		ORDER BY id`, suiteName)
+}
+
+// postgresTestResults selects the test runs of a test suite run, runs without test
+// results have a json `null` instead of an array.
+const postgresTestResults = `jsonb_array_elements(CASE jsonb_typeof(data->'testResults') WHEN 'array' THEN data->'testResults' ELSE '[]' END)`
+
+var postgresDialect = sqlDialect{
+	placeholder:    func(n int) string { return fmt.Sprintf("$%d", n) },
+	timeArg:        func(t time.Time) any { return t },
+	notExpired:     "(expires_at IS NULL OR expires_at > now())",
+	idempotencyKey: "data->>'idempotencyKey'",
+	hasTest:        "EXISTS (SELECT 1 FROM " + postgresTestResults + " tr WHERE tr->>'name' = %s::TEXT)",
+	hasTestContext: "EXISTS (SELECT 1 FROM " + postgresTestResults + " tr WHERE tr->'context'->>%s::TEXT = %s::TEXT)",
+}
+
+func (p *PostgresStorage) ListTestSuiteRuns(
+	ctx context.Context,
+	suiteName string,
+	filter model.TestSuiteRunFilter,
+	page model.Page,
+) ([]model.TestSuiteRun, int, error) {
+	clauses, args := listRunsQuery(postgresDialect, suiteName, filter, page)
+
+	runs, err := p.loadTestSuiteRuns(ctx, `SELECT data FROM test_suite_runs `+clauses, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	runs, next := nextPage(runs, page)
+
+	return runs, next, nil
+}
+
+func (p *PostgresStorage) SearchTestSuiteRuns(
+	ctx context.Context,
+	filter model.TestSuiteRunFilter,
+	page model.SearchPage,
+) ([]model.TestSuiteRun, model.SearchCursor, error) {
+	clauses, args := searchRunsQuery(postgresDialect, filter, page)
+
+	runs, err := p.loadTestSuiteRuns(ctx, `SELECT data FROM test_suite_runs `+clauses, args...)
+	if err != nil {
+		return nil, model.SearchCursor{}, err
+	}
+
+	runs, next := nextSearchPage(runs, page)
+
+	return runs, next, nil
+}
+
+func (p *PostgresStorage) loadTestSuiteRuns(ctx context.Context, query string, args ...any) ([]model.TestSuiteRun, error) {
+	runs := []model.TestSuiteRun{}
+
+	err := queryJSON(ctx, p.q(ctx), query, args, func(data []byte) error {
+		var tsr model.TestSuiteRun
+		if err := json.Unmarshal(data, &tsr); err != nil {
+			return fmt.Errorf("unmarshaling test suite run: %w", err)
+		}
+
+		runs = append(runs, tsr)
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("loading test suite runs: %w", err)
+	}
+
+	return runs, nil
+}
+
+func (p *PostgresStorage) InsertScheduledRun(ctx context.Context, sr model.ScheduledRun) error {
+	data, err := json.Marshal(sr)
+	if err != nil {
+		return fmt.Errorf("marshalling scheduled run: %w", err)
+	}
+
+	_, err = p.q(ctx).ExecContext(ctx, `
+		INSERT INTO scheduled_runs (name, data) VALUES ($1, $2)
+		ON CONFLICT (name) DO UPDATE SET data = EXCLUDED.data`, sr.Name, string(data))
+	if err != nil {
+		return fmt.Errorf("inserting scheduled run: %w", err)
+	}
+
+	return nil
+}
+
+func (p *PostgresStorage) UpdateScheduledRun(ctx context.Context, sr model.ScheduledRun) error {
+	data, err := json.Marshal(sr)
+	if err != nil {
+		return fmt.Errorf("marshalling scheduled run: %w", err)
+	}
+
+	res, err := p.q(ctx).ExecContext(ctx, `UPDATE scheduled_runs SET data = $2 WHERE name = $1`, sr.Name, string(data))
+	if err != nil {
+		return fmt.Errorf("updating scheduled run: %w", err)
+	}
+
+	return expectAffectedRows(res, "updating scheduled run")
+}
+
+func (p *PostgresStorage) LoadScheduledRuns(ctx context.Context) ([]model.ScheduledRun, error) {
+	schedules := []model.ScheduledRun{}
+
+	err := queryJSON(ctx, p.q(ctx), `SELECT data FROM scheduled_runs ORDER BY name`, nil, func(data []byte) error {
+		var sr model.ScheduledRun
+		if err := json.Unmarshal(data, &sr); err != nil {
+			return fmt.Errorf("unmarshaling scheduled run: %w", err)
+		}
+
+		schedules = append(schedules, sr)
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("loading scheduled runs: %w", err)
+	}
+
+	return schedules, nil
+}
+
+func (p *PostgresStorage) DeleteScheduledRun(ctx context.Context, name string) error {
+	res, err := p.q(ctx).ExecContext(ctx, `DELETE FROM scheduled_runs WHERE name = $1`, name)
+	if err != nil {
+		return fmt.Errorf("deleting scheduled run: %w", err)
+	}
+
+	return expectAffectedRows(res, "deleting scheduled run")
+}
+
+func (p *PostgresStorage) SaveExternalTestSuite(ctx context.Context, ts model.ExternalTestSuite) error {
+	data, err := json.Marshal(ts)
+	if err != nil {
+		return fmt.Errorf("marshalling external test suite: %w", err)
+	}
+
+	_, err = p.q(ctx).ExecContext(ctx, `
+		INSERT INTO external_test_suites (name, data) VALUES ($1, $2)
+		ON CONFLICT (name) DO UPDATE SET data = EXCLUDED.data`, ts.Name, string(data))
+	if err != nil {
+		return fmt.Errorf("saving external test suite: %w", err)
+	}
+
+	return nil
+}
+
+func (p *PostgresStorage) LoadExternalTestSuites(ctx context.Context) ([]model.ExternalTestSuite, error) {
+	suites := []model.ExternalTestSuite{}
+
+	err := queryJSON(ctx, p.q(ctx), `SELECT data FROM external_test_suites ORDER BY name`, nil, func(data []byte) error {
+		var ts model.ExternalTestSuite
+		if err := json.Unmarshal(data, &ts); err != nil {
+			return fmt.Errorf("unmarshaling external test suite: %w", err)
+		}
+
+		suites = append(suites, ts)
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("loading external test suites: %w", err)
+	}
+
+	return suites, nil
+}
+
+func (p *PostgresStorage) InsertIdempotencyKey(ctx context.Context, key string) (string, error) {
+	// expired keys are replaced as if they did not exist.
+	res, err := p.q(ctx).ExecContext(ctx, `
+		INSERT INTO idempotency_keys (key) VALUES ($1)
+		ON CONFLICT (key) DO UPDATE SET run_key = NULL, expires_at = NULL
+		WHERE idempotency_keys.expires_at < now()`, key)
+	if err != nil {
+		return "", fmt.Errorf("inserting idempotency key: %w", err)
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return "", err
+	} else if n > 0 {
+		return "", nil
+	}
+
+	var runKey sql.NullString
+
+	err = p.q(ctx).QueryRowContext(ctx, `SELECT run_key FROM idempotency_keys WHERE key = $1`, key).Scan(&runKey)
+	if err != nil {
+		return "", fmt.Errorf("loading idempotency key: %w", err)
+	}
+
+	return runKey.String, model.DuplicateError{}
+}
+
+func (p *PostgresStorage) UpdateIdempotencyKey(
+	ctx context.Context,
+	key string,
+	suiteName string,
+	id int,
+) error {
+	_, err := p.q(ctx).ExecContext(ctx, `
+		UPDATE idempotency_keys SET run_key = $2, expires_at = $3 WHERE key = $1`,
+		key, string(testSuiteRunKey(suiteName, id)), time.Now().Add(72*time.Hour))
+	if err != nil {
+		return fmt.Errorf("updating idempotency key: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,39 @@
+package storage_test
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"os"
+	"testing"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/raphi011/handoff/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestPostgresStorage runs the conformance suite against the database of the
+// `HANDOFF_TEST_POSTGRES_URL` environment variable, it is set by the CI workflow.
+func TestPostgresStorage(t *testing.T) {
+	url := os.Getenv("HANDOFF_TEST_POSTGRES_URL")
+	if url == "" {
+		t.Skip("HANDOFF_TEST_POSTGRES_URL is not set")
+	}
+
+	testStorage(t, func(t *testing.T) storage.Storage {
+		db, err := storage.NewPostgresStorage(url, 0, nil, slog.Default())
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+
+		conn, err := sql.Open("pgx", url)
+		assert.NoError(t, err)
+		defer conn.Close()
+
+		_, err = conn.ExecContext(context.Background(), `TRUNCATE test_suite_run_sequences,
+			test_suite_runs, scheduled_runs, external_test_suites, idempotency_keys`)
+		assert.NoError(t, err)
+
+		return db
+	})
+}
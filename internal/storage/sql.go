@@ -0,0 +1,287 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/raphi011/handoff/internal/model"
+)
+
+// SQLStorageContextKey is the context key of the transactions of the `database/sql`
+// based storage backends.
+type SQLStorageContextKey string
+
+// querier is implemented by both `*sql.DB` and `*sql.Tx`.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func getSQLTx(ctx context.Context) *sql.Tx {
+	tx, _ := ctx.Value(SQLStorageContextKey("transaction")).(*sql.Tx)
+
+	return tx
+}
+
+// sqlDriver returns the first of the driver names that is registered. Handoff does not
+// import any drivers itself to keep the dependencies of the default (badger) setup small.
+func sqlDriver(database string, names []string) (string, error) {
+	drivers := sql.Drivers()
+
+	for _, d := range names {
+		if slices.Contains(drivers, d) {
+			return d, nil
+		}
+	}
+
+	return "", fmt.Errorf("no %s driver registered, import one of %v", database, names)
+}
+
+// migrate runs all migrations that have not been run yet. The version of the schema is
+// the number of migrations that have been run.
+func migrate(ctx context.Context, tx *sql.Tx, migrations []string) error {
+	_, err := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY)`)
+	if err != nil {
+		return fmt.Errorf("creating migrations table: %w", err)
+	}
+
+	var version int
+
+	err = tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	if err != nil {
+		return fmt.Errorf("loading schema version: %w", err)
+	}
+
+	for i := version; i < len(migrations); i++ {
+		if _, err := tx.ExecContext(ctx, migrations[i]); err != nil {
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+
+		// the version is inlined as sqlite and postgres use different placeholders.
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(`INSERT INTO schema_migrations (version) VALUES (%d)`, i+1)); err != nil {
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+// queryJSON runs a query that selects a single json column and calls `scan` for every row.
+func queryJSON(ctx context.Context, q querier, query string, args []any, scan func(data []byte) error) error {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return err
+		}
+
+		if err := scan(data); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// expectAffectedRows returns a `model.NotFoundError` if a statement did not affect any rows.
+func expectAffectedRows(res sql.Result, op string) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, model.NotFoundError{})
+	}
+
+	return nil
+}
+
+// sqlDialect contains the differences between the sql databases that matter to the
+// queries that select test suite runs.
+type sqlDialect struct {
+	// placeholder returns the placeholder of the nth argument.
+	placeholder func(n int) string
+	// timeArg converts a timestamp to an argument.
+	timeArg func(t time.Time) any
+	// notExpired is the condition that excludes expired runs.
+	notExpired string
+	// idempotencyKey is the expression that selects the idempotency key of a run.
+	idempotencyKey string
+	// hasTest is the condition that matches runs with a test whose name is the argument.
+	hasTest string
+	// hasTestContext is the condition that matches runs with a test whose context has the
+	// key of the first argument with the (string) value of the second argument.
+	hasTestContext string
+}
+
+// runConditions collects the conditions of a query and their arguments.
+type runConditions struct {
+	dialect    sqlDialect
+	conditions []string
+	args       []any
+}
+
+// add adds a condition, every `%s` in the condition is replaced with the placeholder of
+// the corresponding argument.
+func (c *runConditions) add(condition string, args ...any) {
+	placeholders := make([]any, len(args))
+
+	for i, arg := range args {
+		c.args = append(c.args, arg)
+		placeholders[i] = c.dialect.placeholder(len(c.args))
+	}
+
+	c.conditions = append(c.conditions, fmt.Sprintf(condition, placeholders...))
+}
+
+// addFilter adds the conditions of a filter.
+func (c *runConditions) addFilter(filter model.TestSuiteRunFilter) {
+	c.conditions = append(c.conditions, c.dialect.notExpired)
+
+	if filter.Result != "" {
+		c.add("result = %s", string(filter.Result))
+	}
+	if len(filter.Results) > 0 {
+		args := make([]any, len(filter.Results))
+		for i, result := range filter.Results {
+			args[i] = string(result)
+		}
+
+		c.add("result IN ("+strings.Repeat("%s, ", len(args)-1)+"%s)", args...)
+	}
+	if filter.Environment != "" {
+		c.add("environment = %s", filter.Environment)
+	}
+	if filter.InitiatedBy != "" {
+		c.add("initiated_by = %s", filter.InitiatedBy)
+	}
+	if filter.Reference != "" {
+		c.add("reference = %s", filter.Reference)
+	}
+	if filter.ScheduleName != "" {
+		c.add("schedule_name = %s", filter.ScheduleName)
+	}
+	if filter.IdempotencyKey != "" {
+		c.add(c.dialect.idempotencyKey+" = %s", filter.IdempotencyKey)
+	}
+	if len(filter.SuiteNames) > 0 {
+		args := make([]any, len(filter.SuiteNames))
+		for i, name := range filter.SuiteNames {
+			args[i] = name
+		}
+
+		c.add("suite_name IN ("+strings.Repeat("%s, ", len(args)-1)+"%s)", args...)
+	}
+	if !filter.From.IsZero() {
+		c.add("scheduled_at >= %s", c.dialect.timeArg(filter.From))
+	}
+	if !filter.To.IsZero() {
+		c.add("scheduled_at <= %s", c.dialect.timeArg(filter.To))
+	}
+	if filter.Flaky != nil {
+		c.add("flaky = %s", *filter.Flaky)
+	}
+	if filter.TestName != "" {
+		c.add(c.dialect.hasTest, filter.TestName)
+	}
+
+	// sorted to keep the queries (and their plans) stable.
+	keys := make([]string, 0, len(filter.Context))
+	for key := range filter.Context {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		c.add(c.dialect.hasTestContext, key, filter.Context[key])
+	}
+}
+
+func (c *runConditions) where() string {
+	return "WHERE " + strings.Join(c.conditions, " AND ")
+}
+
+// listRunsQuery returns the `WHERE`, `ORDER BY` and `LIMIT` clauses and the args that select
+// a page of the runs of a test suite matching a filter. One more run than the limit of the
+// page is selected to find out whether there is a next page.
+func listRunsQuery(
+	dialect sqlDialect,
+	suiteName string,
+	filter model.TestSuiteRunFilter,
+	page model.Page,
+) (string, []any) {
+	c := runConditions{dialect: dialect}
+
+	c.add("suite_name = %s", suiteName)
+	c.addFilter(filter)
+
+	if page.Cursor > 0 {
+		c.add("id < %s", page.Cursor)
+	}
+
+	query := c.where() + " ORDER BY id DESC"
+	if page.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", page.Limit+1)
+	}
+
+	return query, c.args
+}
+
+// searchRunsQuery is like `listRunsQuery()` but selects the runs of all test suites
+// ordered by the time they were scheduled at, their suite name and id.
+func searchRunsQuery(
+	dialect sqlDialect,
+	filter model.TestSuiteRunFilter,
+	page model.SearchPage,
+) (string, []any) {
+	c := runConditions{dialect: dialect}
+
+	c.addFilter(filter)
+
+	if !page.Cursor.IsZero() {
+		c.add("(scheduled_at, suite_name, id) < (%s, %s, %s)",
+			dialect.timeArg(page.Cursor.Scheduled), page.Cursor.SuiteName, page.Cursor.ID)
+	}
+
+	query := c.where() + " ORDER BY scheduled_at DESC, suite_name DESC, id DESC"
+	if page.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", page.Limit+1)
+	}
+
+	return query, c.args
+}
+
+// nextPage cuts the extra run selected by `listRunsQuery()` and returns the cursor of
+// the next page.
+func nextPage(runs []model.TestSuiteRun, page model.Page) ([]model.TestSuiteRun, int) {
+	if page.Limit <= 0 || len(runs) <= page.Limit {
+		return runs, 0
+	}
+
+	runs = runs[:page.Limit]
+
+	return runs, runs[len(runs)-1].ID
+}
+
+// nextSearchPage cuts the extra run selected by `searchRunsQuery()` and returns the
+// cursor of the next page.
+func nextSearchPage(runs []model.TestSuiteRun, page model.SearchPage) ([]model.TestSuiteRun, model.SearchCursor) {
+	if page.Limit <= 0 || len(runs) <= page.Limit {
+		return runs, model.SearchCursor{}
+	}
+
+	runs = runs[:page.Limit]
+
+	return runs, model.SearchCursorOf(runs[len(runs)-1])
+}
@@ -0,0 +1,631 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/raphi011/handoff/internal/model"
+	"github.com/robfig/cron/v3"
+)
+
+// sqliteDrivers are the names of the `database/sql` drivers that can open SQLite databases.
+// Handoff does not import a driver itself, the binary that runs handoff has to register one,
+// e.g. the pure go `modernc.org/sqlite`.
+var sqliteDrivers = []string{"sqlite", "sqlite3"}
+
+// sqliteTimeLayout is the layout of all timestamps, it can be compared with the
+// result of `sqliteNow` and is understood by the sqlite date and time functions.
+const sqliteTimeLayout = "2006-01-02 15:04:05.000"
+
+const sqliteNow = `strftime('%Y-%m-%d %H:%M:%f', 'now')`
+
+// sqliteMigrations contains the statements that create and update the schema. Statements
+// are run in order and must never be changed once released, add new ones instead.
+//
+// Test suite runs and test runs are stored as json in the `data` column, the other columns
+// are copies of the most important fields so that they can be queried with plain sql.
+var sqliteMigrations = []string{
+	`CREATE TABLE test_suite_run_sequences (
+		suite_name TEXT PRIMARY KEY,
+		last_id    INTEGER NOT NULL
+	)`,
+	`CREATE TABLE test_suite_runs (
+		suite_name    TEXT NOT NULL,
+		id            INTEGER NOT NULL,
+		result        TEXT NOT NULL,
+		pending       INTEGER NOT NULL,
+		environment   TEXT NOT NULL,
+		reference     TEXT NOT NULL,
+		initiated_by  TEXT NOT NULL,
+		schedule_name TEXT NOT NULL,
+		flaky         INTEGER NOT NULL,
+		scheduled_at  TEXT,
+		started_at    TEXT,
+		ended_at      TEXT,
+		duration_ms   INTEGER NOT NULL,
+		data          TEXT NOT NULL,
+		expires_at    TEXT,
+		PRIMARY KEY (suite_name, id)
+	)`,
+	`CREATE INDEX test_suite_runs_pending_idx ON test_suite_runs (suite_name, id) WHERE pending`,
+	`CREATE INDEX test_suite_runs_result_idx ON test_suite_runs (suite_name, result)`,
+	`CREATE INDEX test_suite_runs_schedule_name_idx ON test_suite_runs (schedule_name)`,
+	`CREATE INDEX test_suite_runs_started_at_idx ON test_suite_runs (started_at)`,
+	`CREATE INDEX test_suite_runs_search_idx ON test_suite_runs (scheduled_at, suite_name, id)`,
+	`CREATE INDEX test_suite_runs_reference_idx ON test_suite_runs (reference)`,
+	`CREATE TABLE test_runs (
+		suite_name   TEXT NOT NULL,
+		run_id       INTEGER NOT NULL,
+		name         TEXT NOT NULL,
+		attempt      INTEGER NOT NULL,
+		result       TEXT NOT NULL,
+		soft_failure INTEGER NOT NULL,
+		started_at   TEXT,
+		ended_at     TEXT,
+		duration_ms  INTEGER NOT NULL,
+		logs         TEXT NOT NULL,
+		data         TEXT NOT NULL,
+		PRIMARY KEY (suite_name, run_id, name, attempt),
+		FOREIGN KEY (suite_name, run_id) REFERENCES test_suite_runs (suite_name, id) ON DELETE CASCADE
+	)`,
+	`CREATE INDEX test_runs_name_idx ON test_runs (suite_name, name, result)`,
+	`CREATE TABLE scheduled_runs (
+		name       TEXT PRIMARY KEY,
+		suite_name TEXT NOT NULL,
+		schedule   TEXT NOT NULL,
+		paused     INTEGER NOT NULL,
+		data       TEXT NOT NULL
+	)`,
+	`CREATE TABLE external_test_suites (
+		name TEXT PRIMARY KEY,
+		data TEXT NOT NULL
+	)`,
+	`CREATE TABLE idempotency_keys (
+		key        TEXT PRIMARY KEY,
+		run_key    TEXT,
+		expires_at TEXT
+	)`,
+	`CREATE INDEX idempotency_keys_expires_at_idx ON idempotency_keys (expires_at)`,
+}
+
+type SQLiteStorage struct {
+	db  *sql.DB
+	log *slog.Logger
+	ttl time.Duration
+}
+
+// NewSQLiteStorage opens the SQLite database at `path` and migrates its schema. If
+// the path is empty an in-memory database is used.
+func NewSQLiteStorage(path string,
+	ttl time.Duration,
+	gcCron *cron.Cron,
+	log *slog.Logger,
+) (*SQLiteStorage, error) {
+	driver, err := sqlDriver("sqlite", sqliteDrivers)
+	if err != nil {
+		return nil, err
+	}
+
+	if path == "" {
+		path = ":memory:"
+	}
+
+	db, err := sql.Open(driver, path)
+	if err != nil {
+		return nil, fmt.Errorf("opening sqlite database: %w", err)
+	}
+
+	// sqlite only supports a single writer, using a single connection avoids
+	// busy errors and makes sure that the pragmas and in-memory databases
+	// are shared by all queries.
+	db.SetMaxOpenConns(1)
+
+	ctx := context.Background()
+
+	for _, pragma := range []string{
+		`PRAGMA journal_mode = WAL`,
+		`PRAGMA foreign_keys = ON`,
+		`PRAGMA busy_timeout = 5000`,
+	} {
+		if _, err := db.ExecContext(ctx, pragma); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("configuring sqlite database: %w", err)
+		}
+	}
+
+	s := &SQLiteStorage{
+		db:  db,
+		log: log,
+		ttl: ttl,
+	}
+
+	if err := s.migrate(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrating sqlite database: %w", err)
+	}
+
+	if gcCron != nil {
+		_, err = gcCron.AddFunc("@every 5m", func() {
+			if err := s.deleteExpired(context.Background()); err != nil {
+				log.Warn("sqlite gc", "error", err)
+			}
+		})
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// migrate runs all migrations that have not been run yet.
+func (s *SQLiteStorage) migrate(ctx context.Context) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := migrate(ctx, tx, sqliteMigrations); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// deleteExpired removes the test suite runs (including their test runs) and idempotency
+// keys that have expired.
+func (s *SQLiteStorage) deleteExpired(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM test_suite_runs WHERE expires_at < `+sqliteNow); err != nil {
+		return fmt.Errorf("deleting expired test suite runs: %w", err)
+	}
+
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at < `+sqliteNow); err != nil {
+		return fmt.Errorf("deleting expired idempotency keys: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQLiteStorage) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLiteStorage) StartTransaction(ctx context.Context) (context.Context, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return ctx, err
+	}
+
+	return context.WithValue(ctx, SQLStorageContextKey("transaction"), tx), nil
+}
+
+// q returns the transaction of the context or the database if there is none.
+func (s *SQLiteStorage) q(ctx context.Context) querier {
+	if tx := getSQLTx(ctx); tx != nil {
+		return tx
+	}
+
+	return s.db
+}
+
+// runTx runs `f` in the transaction of the context or, if there is none, in a new
+// transaction that is committed if `f` succeeds.
+func (s *SQLiteStorage) runTx(ctx context.Context, f func(q querier) error) error {
+	if tx := getSQLTx(ctx); tx != nil {
+		return f(tx)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := f(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLiteStorage) CommitTransaction(ctx context.Context) error {
+	return getSQLTx(ctx).Commit()
+}
+
+func (s *SQLiteStorage) RollbackTransaction(ctx context.Context) {
+	_ = getSQLTx(ctx).Rollback()
+}
+
+// sqliteTime formats a timestamp, zero timestamps are stored as NULL.
+func sqliteTime(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+
+	return t.UTC().Format(sqliteTimeLayout)
+}
+
+func (s *SQLiteStorage) InsertTestSuiteRun(ctx context.Context, tsr model.TestSuiteRun) (int, error) {
+	err := s.runTx(ctx, func(q querier) error {
+		err := q.QueryRowContext(ctx, `
+			INSERT INTO test_suite_run_sequences (suite_name, last_id) VALUES (?, 1)
+			ON CONFLICT (suite_name) DO UPDATE SET last_id = last_id + 1
+			RETURNING last_id`, tsr.SuiteName).Scan(&tsr.ID)
+		if err != nil {
+			return fmt.Errorf("unable to get next sequence: %w", err)
+		}
+
+		return s.saveTestSuiteRun(ctx, q, tsr, true)
+	})
+	if err != nil {
+		return -1, err
+	}
+
+	return tsr.ID, nil
+}
+
+func (s *SQLiteStorage) UpdateTestSuiteRun(ctx context.Context, tsr model.TestSuiteRun) error {
+	return s.runTx(ctx, func(q querier) error {
+		return s.saveTestSuiteRun(ctx, q, tsr, false)
+	})
+}
+
+// saveTestSuiteRun inserts or replaces a test suite run and all of its test runs.
+func (s *SQLiteStorage) saveTestSuiteRun(ctx context.Context, q querier, tsr model.TestSuiteRun, insert bool) error {
+	testRuns := tsr.TestResults
+	tsr.TestResults = nil
+
+	data, err := json.Marshal(tsr)
+	if err != nil {
+		return fmt.Errorf("marshalling test suite run: %w", err)
+	}
+
+	pending := tsr.Result == model.ResultPending
+
+	var expiresAt any
+	if !pending && s.ttl > 0 {
+		expiresAt = sqliteTime(time.Now().Add(s.ttl))
+	}
+
+	args := []any{
+		tsr.SuiteName, tsr.ID, tsr.Result, pending, tsr.Environment, tsr.Reference,
+		tsr.InitiatedBy, tsr.ScheduleName, tsr.Flaky, sqliteTime(tsr.Scheduled),
+		sqliteTime(tsr.Start), sqliteTime(tsr.End), tsr.DurationInMS, string(data), expiresAt,
+	}
+
+	if insert {
+		_, err = q.ExecContext(ctx, `
+			INSERT INTO test_suite_runs (
+				suite_name, id, result, pending, environment, reference, initiated_by,
+				schedule_name, flaky, scheduled_at, started_at, ended_at, duration_ms,
+				data, expires_at
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, args...)
+		if err != nil {
+			return fmt.Errorf("inserting test suite run: %w", err)
+		}
+	} else {
+		res, err := q.ExecContext(ctx, `
+			UPDATE test_suite_runs SET
+				result = ?3, pending = ?4, environment = ?5, reference = ?6, initiated_by = ?7,
+				schedule_name = ?8, flaky = ?9, scheduled_at = ?10, started_at = ?11,
+				ended_at = ?12, duration_ms = ?13, data = ?14, expires_at = ?15
+			WHERE suite_name = ?1 AND id = ?2`, args...)
+		if err != nil {
+			return fmt.Errorf("updating test suite run: %w", err)
+		}
+
+		if err := expectAffectedRows(res, "updating test suite run"); err != nil {
+			return err
+		}
+
+		_, err = q.ExecContext(ctx, `DELETE FROM test_runs WHERE suite_name = ? AND run_id = ?`, tsr.SuiteName, tsr.ID)
+		if err != nil {
+			return fmt.Errorf("deleting test runs: %w", err)
+		}
+	}
+
+	for _, tr := range testRuns {
+		tr.SuiteName = tsr.SuiteName
+		tr.SuiteRunID = tsr.ID
+
+		data, err := json.Marshal(tr)
+		if err != nil {
+			return fmt.Errorf("marshalling test run: %w", err)
+		}
+
+		_, err = q.ExecContext(ctx, `
+			INSERT INTO test_runs (
+				suite_name, run_id, name, attempt, result, soft_failure,
+				started_at, ended_at, duration_ms, logs, data
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			tsr.SuiteName, tsr.ID, tr.Name, tr.Attempt, tr.Result, tr.SoftFailure,
+			sqliteTime(tr.Start), sqliteTime(tr.End), tr.DurationInMS, tr.Logs, string(data))
+		if err != nil {
+			return fmt.Errorf("inserting test run: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *SQLiteStorage) LoadTestSuiteRunByKey(ctx context.Context, key string) (model.TestSuiteRun, error) {
+	suiteName, runID, err := parseTestSuiteRunKey(key)
+	if err != nil {
+		return model.TestSuiteRun{}, err
+	}
+
+	return s.LoadTestSuiteRun(ctx, suiteName, runID)
+}
+
+func (s *SQLiteStorage) LoadTestSuiteRun(ctx context.Context, suiteName string, runID int) (model.TestSuiteRun, error) {
+	runs, err := s.loadTestSuiteRuns(ctx, `
+		WHERE suite_name = ? AND id = ? AND (expires_at IS NULL OR expires_at > `+sqliteNow+`)`,
+		suiteName, runID)
+	if err != nil {
+		return model.TestSuiteRun{}, err
+	}
+
+	if len(runs) == 0 {
+		return model.TestSuiteRun{}, model.NotFoundError{}
+	}
+
+	return runs[0], nil
+}
+
+func (s *SQLiteStorage) LoadPendingTestSuiteRuns(ctx context.Context) ([]model.TestSuiteRun, error) {
+	return s.loadTestSuiteRuns(ctx, `WHERE pending ORDER BY suite_name, id`)
+}
+
+func (s *SQLiteStorage) LoadTestSuiteRunsByName(ctx context.Context, suiteName string) ([]model.TestSuiteRun, error) {
+	return s.loadTestSuiteRuns(ctx, `
+		WHERE suite_name = ? AND (expires_at IS NULL OR expires_at > `+sqliteNow+`)
+		ORDER BY id`, suiteName)
+}
+
+// sqliteTestRuns matches the test runs of a test suite run.
+const sqliteTestRuns = `test_runs tr WHERE tr.suite_name = test_suite_runs.suite_name AND tr.run_id = test_suite_runs.id`
+
+var sqliteDialect = sqlDialect{
+	placeholder:    func(int) string { return "?" },
+	timeArg:        sqliteTime,
+	notExpired:     "(expires_at IS NULL OR expires_at > " + sqliteNow + ")",
+	idempotencyKey: "json_extract(data, '$.idempotencyKey')",
+	hasTest:        "EXISTS (SELECT 1 FROM " + sqliteTestRuns + " AND tr.name = %s)",
+	hasTestContext: "EXISTS (SELECT 1 FROM " + sqliteTestRuns + " AND CAST(json_extract(tr.data, '$.context.' || json_quote(%s)) AS TEXT) = %s)",
+}
+
+func (s *SQLiteStorage) ListTestSuiteRuns(
+	ctx context.Context,
+	suiteName string,
+	filter model.TestSuiteRunFilter,
+	page model.Page,
+) ([]model.TestSuiteRun, int, error) {
+	clauses, args := listRunsQuery(sqliteDialect, suiteName, filter, page)
+
+	runs, err := s.loadTestSuiteRuns(ctx, clauses, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	runs, next := nextPage(runs, page)
+
+	return runs, next, nil
+}
+
+func (s *SQLiteStorage) SearchTestSuiteRuns(
+	ctx context.Context,
+	filter model.TestSuiteRunFilter,
+	page model.SearchPage,
+) ([]model.TestSuiteRun, model.SearchCursor, error) {
+	clauses, args := searchRunsQuery(sqliteDialect, filter, page)
+
+	runs, err := s.loadTestSuiteRuns(ctx, clauses, args...)
+	if err != nil {
+		return nil, model.SearchCursor{}, err
+	}
+
+	runs, next := nextSearchPage(runs, page)
+
+	return runs, next, nil
+}
+
+// loadTestSuiteRuns loads the test suite runs selected by the `clauses` (e.g. `WHERE`
+// and `ORDER BY`) together with their test runs.
+func (s *SQLiteStorage) loadTestSuiteRuns(ctx context.Context, clauses string, args ...any) ([]model.TestSuiteRun, error) {
+	runs := []model.TestSuiteRun{}
+	index := map[string]int{}
+
+	err := s.runTx(ctx, func(q querier) error {
+		err := queryJSON(ctx, q, `SELECT data FROM test_suite_runs `+clauses, args, func(data []byte) error {
+			var tsr model.TestSuiteRun
+			if err := json.Unmarshal(data, &tsr); err != nil {
+				return fmt.Errorf("unmarshaling test suite run: %w", err)
+			}
+
+			tsr.TestResults = []model.TestRun{}
+			index[string(testSuiteRunKey(tsr.SuiteName, tsr.ID))] = len(runs)
+			runs = append(runs, tsr)
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if len(runs) == 0 {
+			return nil
+		}
+
+		return queryJSON(ctx, q, `
+			SELECT data FROM test_runs WHERE (suite_name, run_id) IN (
+				SELECT suite_name, id FROM test_suite_runs `+clauses+`
+			) ORDER BY suite_name, run_id, rowid`, args, func(data []byte) error {
+			var tr model.TestRun
+			if err := json.Unmarshal(data, &tr); err != nil {
+				return fmt.Errorf("unmarshaling test run: %w", err)
+			}
+
+			if i, ok := index[string(testSuiteRunKey(tr.SuiteName, tr.SuiteRunID))]; ok {
+				runs[i].TestResults = append(runs[i].TestResults, tr)
+			}
+
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("loading test suite runs: %w", err)
+	}
+
+	return runs, nil
+}
+
+func (s *SQLiteStorage) InsertScheduledRun(ctx context.Context, sr model.ScheduledRun) error {
+	data, err := json.Marshal(sr)
+	if err != nil {
+		return fmt.Errorf("marshalling scheduled run: %w", err)
+	}
+
+	_, err = s.q(ctx).ExecContext(ctx, `
+		INSERT INTO scheduled_runs (name, suite_name, schedule, paused, data) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET
+			suite_name = excluded.suite_name, schedule = excluded.schedule,
+			paused = excluded.paused, data = excluded.data`,
+		sr.Name, sr.TestSuiteName, sr.Schedule, sr.Paused, string(data))
+	if err != nil {
+		return fmt.Errorf("inserting scheduled run: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQLiteStorage) UpdateScheduledRun(ctx context.Context, sr model.ScheduledRun) error {
+	data, err := json.Marshal(sr)
+	if err != nil {
+		return fmt.Errorf("marshalling scheduled run: %w", err)
+	}
+
+	res, err := s.q(ctx).ExecContext(ctx, `
+		UPDATE scheduled_runs SET suite_name = ?, schedule = ?, paused = ?, data = ?
+		WHERE name = ?`, sr.TestSuiteName, sr.Schedule, sr.Paused, string(data), sr.Name)
+	if err != nil {
+		return fmt.Errorf("updating scheduled run: %w", err)
+	}
+
+	return expectAffectedRows(res, "updating scheduled run")
+}
+
+func (s *SQLiteStorage) LoadScheduledRuns(ctx context.Context) ([]model.ScheduledRun, error) {
+	schedules := []model.ScheduledRun{}
+
+	err := queryJSON(ctx, s.q(ctx), `SELECT data FROM scheduled_runs ORDER BY name`, nil, func(data []byte) error {
+		var sr model.ScheduledRun
+		if err := json.Unmarshal(data, &sr); err != nil {
+			return fmt.Errorf("unmarshaling scheduled run: %w", err)
+		}
+
+		schedules = append(schedules, sr)
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("loading scheduled runs: %w", err)
+	}
+
+	return schedules, nil
+}
+
+func (s *SQLiteStorage) DeleteScheduledRun(ctx context.Context, name string) error {
+	res, err := s.q(ctx).ExecContext(ctx, `DELETE FROM scheduled_runs WHERE name = ?`, name)
+	if err != nil {
+		return fmt.Errorf("deleting scheduled run: %w", err)
+	}
+
+	return expectAffectedRows(res, "deleting scheduled run")
+}
+
+func (s *SQLiteStorage) SaveExternalTestSuite(ctx context.Context, ts model.ExternalTestSuite) error {
+	data, err := json.Marshal(ts)
+	if err != nil {
+		return fmt.Errorf("marshalling external test suite: %w", err)
+	}
+
+	_, err = s.q(ctx).ExecContext(ctx, `
+		INSERT INTO external_test_suites (name, data) VALUES (?, ?)
+		ON CONFLICT (name) DO UPDATE SET data = excluded.data`, ts.Name, string(data))
+	if err != nil {
+		return fmt.Errorf("saving external test suite: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQLiteStorage) LoadExternalTestSuites(ctx context.Context) ([]model.ExternalTestSuite, error) {
+	suites := []model.ExternalTestSuite{}
+
+	err := queryJSON(ctx, s.q(ctx), `SELECT data FROM external_test_suites ORDER BY name`, nil, func(data []byte) error {
+		var ts model.ExternalTestSuite
+		if err := json.Unmarshal(data, &ts); err != nil {
+			return fmt.Errorf("unmarshaling external test suite: %w", err)
+		}
+
+		suites = append(suites, ts)
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("loading external test suites: %w", err)
+	}
+
+	return suites, nil
+}
+
+func (s *SQLiteStorage) InsertIdempotencyKey(ctx context.Context, key string) (string, error) {
+	// expired keys are replaced as if they did not exist.
+	res, err := s.q(ctx).ExecContext(ctx, `
+		INSERT INTO idempotency_keys (key) VALUES (?)
+		ON CONFLICT (key) DO UPDATE SET run_key = NULL, expires_at = NULL
+		WHERE idempotency_keys.expires_at < `+sqliteNow, key)
+	if err != nil {
+		return "", fmt.Errorf("inserting idempotency key: %w", err)
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return "", err
+	} else if n > 0 {
+		return "", nil
+	}
+
+	var runKey sql.NullString
+
+	err = s.q(ctx).QueryRowContext(ctx, `SELECT run_key FROM idempotency_keys WHERE key = ?`, key).Scan(&runKey)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", model.DuplicateError{}
+	} else if err != nil {
+		return "", fmt.Errorf("loading idempotency key: %w", err)
+	}
+
+	return runKey.String, model.DuplicateError{}
+}
+
+func (s *SQLiteStorage) UpdateIdempotencyKey(
+	ctx context.Context,
+	key string,
+	suiteName string,
+	id int,
+) error {
+	_, err := s.q(ctx).ExecContext(ctx, `
+		UPDATE idempotency_keys SET run_key = ?, expires_at = ? WHERE key = ?`,
+		string(testSuiteRunKey(suiteName, id)), sqliteTime(time.Now().Add(72*time.Hour)), key)
+	if err != nil {
+		return fmt.Errorf("updating idempotency key: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,23 @@
+package storage_test
+
+import (
+	"log/slog"
+	"path/filepath"
+	"testing"
+
+	"github.com/raphi011/handoff/internal/storage"
+	"github.com/stretchr/testify/assert"
+	_ "modernc.org/sqlite"
+)
+
+// TestSQLiteStorage runs the conformance suite against a SQLite database.
+func TestSQLiteStorage(t *testing.T) {
+	testStorage(t, func(t *testing.T) storage.Storage {
+		db, err := storage.NewSQLiteStorage(filepath.Join(t.TempDir(), "handoff.sqlite"), 0, nil, slog.Default())
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+
+		return db
+	})
+}
@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/raphi011/handoff/internal/model"
+	"github.com/robfig/cron/v3"
+)
+
+// Storage persists test suite runs, schedules, external test suites and idempotency keys.
+//
+// Methods that are called with a context returned by `StartTransaction` are run as part
+// of that transaction until it is committed or rolled back.
+type Storage interface {
+	Close() error
+
+	StartTransaction(ctx context.Context) (context.Context, error)
+	CommitTransaction(ctx context.Context) error
+	RollbackTransaction(ctx context.Context)
+
+	// InsertTestSuiteRun persists a new test suite run and returns its id. Ids start
+	// with 1 and are incremented per test suite.
+	InsertTestSuiteRun(ctx context.Context, tsr model.TestSuiteRun) (int, error)
+	// UpdateTestSuiteRun replaces a test suite run. Runs that are no longer pending
+	// expire after the configured TTL.
+	UpdateTestSuiteRun(ctx context.Context, tsr model.TestSuiteRun) error
+	LoadTestSuiteRun(ctx context.Context, suiteName string, runID int) (model.TestSuiteRun, error)
+	// LoadTestSuiteRunByKey loads a test suite run by the key that is stored with an
+	// idempotency key.
+	LoadTestSuiteRunByKey(ctx context.Context, key string) (model.TestSuiteRun, error)
+	LoadPendingTestSuiteRuns(ctx context.Context) ([]model.TestSuiteRun, error)
+	LoadTestSuiteRunsByName(ctx context.Context, suiteName string) ([]model.TestSuiteRun, error)
+	// ListTestSuiteRuns returns a page of the runs of a test suite that match the filter
+	// ordered from the newest to the oldest run, and the cursor of the next page which is 0
+	// if there are no more runs. A page without a limit contains all runs.
+	ListTestSuiteRuns(
+		ctx context.Context,
+		suiteName string,
+		filter model.TestSuiteRunFilter,
+		page model.Page,
+	) ([]model.TestSuiteRun, int, error)
+	// SearchTestSuiteRuns returns a page of the runs of all test suites that match the
+	// filter ordered from the newest to the oldest run (by the time they were scheduled at),
+	// and the cursor of the next page which is zero if there are no more runs.
+	SearchTestSuiteRuns(
+		ctx context.Context,
+		filter model.TestSuiteRunFilter,
+		page model.SearchPage,
+	) ([]model.TestSuiteRun, model.SearchCursor, error)
+
+	InsertScheduledRun(ctx context.Context, sr model.ScheduledRun) error
+	UpdateScheduledRun(ctx context.Context, sr model.ScheduledRun) error
+	LoadScheduledRuns(ctx context.Context) ([]model.ScheduledRun, error)
+	DeleteScheduledRun(ctx context.Context, name string) error
+
+	// SaveExternalTestSuite inserts or replaces an external test suite.
+	SaveExternalTestSuite(ctx context.Context, ts model.ExternalTestSuite) error
+	LoadExternalTestSuites(ctx context.Context) ([]model.ExternalTestSuite, error)
+
+	// InsertIdempotencyKey reserves an idempotency key. If the key exists a
+	// `model.DuplicateError` is returned together with the key of the test suite run
+	// that was created with it, if there is one yet.
+	InsertIdempotencyKey(ctx context.Context, key string) (string, error)
+	// UpdateIdempotencyKey links an idempotency key to the test suite run that was
+	// created with it.
+	UpdateIdempotencyKey(ctx context.Context, key string, suiteName string, id int) error
+}
+
+var (
+	_ Storage = &BadgerStorage{}
+	_ Storage = &PostgresStorage{}
+	_ Storage = &SQLiteStorage{}
+)
+
+// Open opens the storage for a database location. URLs with the `postgres` or
+// `postgresql` scheme connect to a PostgreSQL database, `sqlite://<path>` opens a SQLite
+// database and everything else is treated as the path of a badger database. An empty
+// path opens an in-memory database.
+func Open(database string,
+	ttl time.Duration,
+	gcCron *cron.Cron,
+	log *slog.Logger,
+) (Storage, error) {
+	scheme, path, found := strings.Cut(database, "://")
+	if !found {
+		return NewBadgerStorage(database, ttl, gcCron, log)
+	}
+
+	switch scheme {
+	case "badger":
+		return NewBadgerStorage(path, ttl, gcCron, log)
+	case "sqlite":
+		return NewSQLiteStorage(path, ttl, gcCron, log)
+	case "postgres", "postgresql":
+		return NewPostgresStorage(database, ttl, gcCron, log)
+	default:
+		return nil, fmt.Errorf("unsupported database scheme %q", scheme)
+	}
+}
+
+func testSuiteRunKey(suiteName string, id int) []byte {
+	return []byte(fmt.Sprintf("suite-%s-%v", suiteName, id))
+}
+
+// parseTestSuiteRunKey returns the suite name and run id of a key
+// created by `testSuiteRunKey()`.
+func parseTestSuiteRunKey(key string) (string, int, error) {
+	parts := strings.Split(key, "-")
+
+	if len(parts) < 3 {
+		return "", 0, fmt.Errorf("invalid testsuite run key: %s", key)
+	}
+
+	suiteName := strings.Join(parts[1:len(parts)-1], "-")
+	runID, err := strconv.Atoi(parts[len(parts)-1])
+	if err != nil {
+		return "", 0, fmt.Errorf("id part of the key %s it not an int", key)
+	}
+
+	return suiteName, runID, nil
+}
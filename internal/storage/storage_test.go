@@ -0,0 +1,366 @@
+package storage_test
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/raphi011/handoff/internal/model"
+	"github.com/raphi011/handoff/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+// testStorage is the conformance suite that every storage backend has to pass,
+// `open` must return an empty storage.
+func testStorage(t *testing.T, open func(t *testing.T) storage.Storage) {
+	tests := map[string]func(t *testing.T, db storage.Storage){
+		"TestSuiteRuns":                testTestSuiteRuns,
+		"ListTestSuiteRuns":            testListTestSuiteRuns,
+		"SearchTestSuiteRuns":          testSearchTestSuiteRuns,
+		"Transaction":                  testTransaction,
+		"DeleteScheduledRun":           testDeleteScheduledRun,
+		"UpdateAndLoadScheduledRuns":   testUpdateAndLoadScheduledRuns,
+		"SaveAndLoadExternalTestSuite": testSaveAndLoadExternalTestSuites,
+		"IdempotencyKey":               testIdempotencyKey,
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			db := open(t)
+			defer db.Close()
+
+			test(t, db)
+		})
+	}
+}
+
+func testTestSuiteRuns(t *testing.T, db storage.Storage) {
+	ctx := context.Background()
+
+	suiteName := "test-suite"
+
+	tr := model.TestRun{
+		SuiteName: suiteName,
+		Attempt:   1,
+		Name:      "Bla",
+		Result:    model.ResultPending,
+	}
+
+	id, err := db.InsertTestSuiteRun(ctx, model.TestSuiteRun{
+		SuiteName:   suiteName,
+		Result:      model.ResultPending,
+		TestResults: []model.TestRun{tr},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, id)
+
+	id, err = db.InsertTestSuiteRun(ctx, model.TestSuiteRun{
+		SuiteName: suiteName,
+		Result:    model.ResultPending,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, id)
+
+	tsr, err := db.LoadTestSuiteRun(ctx, suiteName, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, tsr.ID)
+	assert.Len(t, tsr.TestResults, 1)
+
+	var notFoundErr model.NotFoundError
+
+	_, err = db.LoadTestSuiteRun(ctx, suiteName, 3)
+	assert.ErrorAs(t, err, &notFoundErr)
+
+	runs, err := db.LoadTestSuiteRunsByName(ctx, suiteName)
+	assert.NoError(t, err)
+	assert.Len(t, runs, 2)
+
+	pending, err := db.LoadPendingTestSuiteRuns(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, pending, 2)
+
+	tsr.Result = model.ResultPassed
+	tsr.TestResults[0].Result = model.ResultPassed
+	assert.NoError(t, db.UpdateTestSuiteRun(ctx, tsr))
+
+	pending, err = db.LoadPendingTestSuiteRuns(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, pending, 1)
+	assert.Equal(t, 2, pending[0].ID)
+
+	tsr, err = db.LoadTestSuiteRunByKey(ctx, "suite-test-suite-1")
+	assert.NoError(t, err)
+	assert.Equal(t, model.ResultPassed, tsr.Result)
+	assert.Equal(t, model.ResultPassed, tsr.TestResults[0].Result)
+}
+
+func testListTestSuiteRuns(t *testing.T, db storage.Storage) {
+	ctx := context.Background()
+
+	suiteName := "my"
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// runs of a suite whose name starts with the name of the listed suite must not be listed
+	_, err := db.InsertTestSuiteRun(ctx, model.TestSuiteRun{SuiteName: "my-app", Result: model.ResultPending})
+	assert.NoError(t, err)
+
+	for i := range 5 {
+		tsr := model.TestSuiteRun{
+			SuiteName:   suiteName,
+			Result:      model.ResultPending,
+			Environment: "staging",
+			Scheduled:   start.Add(time.Duration(i) * time.Hour),
+		}
+
+		tsr.ID, err = db.InsertTestSuiteRun(ctx, tsr)
+		assert.NoError(t, err)
+
+		tsr.Result = model.ResultPassed
+		if i%2 == 1 {
+			tsr.Result = model.ResultFailed
+			tsr.Flaky = true
+			tsr.Reference = "main"
+		}
+
+		assert.NoError(t, db.UpdateTestSuiteRun(ctx, tsr))
+	}
+
+	ids := func(runs []model.TestSuiteRun) []int {
+		ids := []int{}
+		for _, tsr := range runs {
+			ids = append(ids, tsr.ID)
+		}
+		return ids
+	}
+
+	runs, err := db.LoadTestSuiteRunsByName(ctx, suiteName)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, ids(runs))
+
+	runs, next, err := db.ListTestSuiteRuns(ctx, suiteName, model.TestSuiteRunFilter{}, model.Page{Limit: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{5, 4}, ids(runs), "expected the newest runs first")
+	assert.Equal(t, 4, next)
+
+	runs, next, err = db.ListTestSuiteRuns(ctx, suiteName, model.TestSuiteRunFilter{}, model.Page{Limit: 2, Cursor: next})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{3, 2}, ids(runs))
+	assert.Equal(t, 2, next)
+
+	runs, next, err = db.ListTestSuiteRuns(ctx, suiteName, model.TestSuiteRunFilter{}, model.Page{Limit: 2, Cursor: next})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1}, ids(runs))
+	assert.Equal(t, 0, next, "expected no next page")
+
+	flaky := true
+
+	for name, test := range map[string]struct {
+		filter model.TestSuiteRunFilter
+		ids    []int
+	}{
+		"result":      {model.TestSuiteRunFilter{Result: model.ResultFailed}, []int{4, 2}},
+		"results":     {model.TestSuiteRunFilter{Results: []model.Result{model.ResultFailed, model.ResultCancelled}}, []int{4, 2}},
+		"reference":   {model.TestSuiteRunFilter{Reference: "main", Result: model.ResultFailed}, []int{4, 2}},
+		"environment": {model.TestSuiteRunFilter{Environment: "production"}, []int{}},
+		"flaky":       {model.TestSuiteRunFilter{Flaky: &flaky}, []int{4, 2}},
+		"time range":  {model.TestSuiteRunFilter{From: start.Add(time.Hour), To: start.Add(3 * time.Hour)}, []int{4, 3, 2}},
+	} {
+		runs, _, err := db.ListTestSuiteRuns(ctx, suiteName, test.filter, model.Page{})
+		assert.NoError(t, err, name)
+		assert.Equal(t, test.ids, ids(runs), name)
+	}
+}
+
+func testSearchTestSuiteRuns(t *testing.T, db storage.Storage) {
+	ctx := context.Background()
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// runs of two suites that are scheduled alternately
+	for i := range 6 {
+		suiteName := []string{"api", "web"}[i%2]
+
+		tsr := model.TestSuiteRun{
+			SuiteName:      suiteName,
+			Result:         model.ResultPending,
+			Reference:      "main",
+			IdempotencyKey: fmt.Sprintf("key-%d", i),
+			Scheduled:      start.Add(time.Duration(i) * time.Hour),
+		}
+
+		var err error
+		tsr.ID, err = db.InsertTestSuiteRun(ctx, tsr)
+		assert.NoError(t, err)
+
+		tsr.Result = model.ResultPassed
+		tsr.TestResults = []model.TestRun{{
+			SuiteName:  suiteName,
+			SuiteRunID: tsr.ID,
+			Name:       "TestLogin",
+			Attempt:    1,
+			Result:     model.ResultPassed,
+			Context:    model.TestContext{"correlation-id": fmt.Sprintf("c-%d", i)},
+		}}
+		if i >= 4 {
+			tsr.Reference = "PR #1234"
+			tsr.TestResults[0].Name = "TestCheckout"
+		}
+
+		assert.NoError(t, db.UpdateTestSuiteRun(ctx, tsr))
+	}
+
+	runIDs := func(runs []model.TestSuiteRun) []string {
+		ids := []string{}
+		for _, tsr := range runs {
+			ids = append(ids, fmt.Sprintf("%s/%d", tsr.SuiteName, tsr.ID))
+		}
+		return ids
+	}
+
+	runs, next, err := db.SearchTestSuiteRuns(ctx, model.TestSuiteRunFilter{}, model.SearchPage{Limit: 4})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"web/3", "api/3", "web/2", "api/2"}, runIDs(runs), "expected the newest runs first")
+	assert.False(t, next.IsZero())
+
+	runs, next, err = db.SearchTestSuiteRuns(ctx, model.TestSuiteRunFilter{}, model.SearchPage{Limit: 4, Cursor: next})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"web/1", "api/1"}, runIDs(runs))
+	assert.True(t, next.IsZero(), "expected no next page")
+
+	for name, test := range map[string]struct {
+		filter model.TestSuiteRunFilter
+		runs   []string
+	}{
+		"reference":       {model.TestSuiteRunFilter{Reference: "PR #1234"}, []string{"web/3", "api/3"}},
+		"idempotency key": {model.TestSuiteRunFilter{IdempotencyKey: "key-2"}, []string{"api/2"}},
+		"suite names":     {model.TestSuiteRunFilter{Reference: "main", SuiteNames: []string{"web"}}, []string{"web/2", "web/1"}},
+		"test name":       {model.TestSuiteRunFilter{TestName: "TestCheckout"}, []string{"web/3", "api/3"}},
+		"context":         {model.TestSuiteRunFilter{Context: map[string]string{"correlation-id": "c-3"}}, []string{"web/2"}},
+		"no match":        {model.TestSuiteRunFilter{Context: map[string]string{"correlation-id": "c-9"}}, []string{}},
+	} {
+		runs, _, err := db.SearchTestSuiteRuns(ctx, test.filter, model.SearchPage{})
+		assert.NoError(t, err, name)
+		assert.Equal(t, test.runs, runIDs(runs), name)
+	}
+}
+
+func testTransaction(t *testing.T, db storage.Storage) {
+	ctx, err := db.StartTransaction(context.Background())
+	assert.NoError(t, err)
+
+	_, err = db.InsertTestSuiteRun(ctx, model.TestSuiteRun{SuiteName: "committed"})
+	assert.NoError(t, err)
+	assert.NoError(t, db.CommitTransaction(ctx))
+
+	_, err = db.LoadTestSuiteRun(context.Background(), "committed", 1)
+	assert.NoError(t, err, "expected committed test suite run to be persisted")
+
+	ctx, err = db.StartTransaction(context.Background())
+	assert.NoError(t, err)
+
+	assert.NoError(t, db.InsertScheduledRun(ctx, model.ScheduledRun{Name: "rolled-back"}))
+	db.RollbackTransaction(ctx)
+
+	schedules, err := db.LoadScheduledRuns(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, schedules, "expected rolled back schedule not to be persisted")
+}
+
+func testDeleteScheduledRun(t *testing.T, db storage.Storage) {
+	ctx := context.Background()
+
+	assert.NoError(t, db.InsertScheduledRun(ctx, model.ScheduledRun{
+		Name:          "sr",
+		TestSuiteName: "sn",
+	}))
+
+	err := db.DeleteScheduledRun(ctx, "sr")
+	assert.NoError(t, err)
+
+	var notFoundErr model.NotFoundError
+
+	err = db.DeleteScheduledRun(ctx, "sr")
+	assert.ErrorAs(t, err, &notFoundErr)
+}
+
+func testUpdateAndLoadScheduledRuns(t *testing.T, db storage.Storage) {
+	ctx := context.Background()
+
+	var notFoundErr model.NotFoundError
+
+	err := db.UpdateScheduledRun(ctx, model.ScheduledRun{Name: "sr"})
+	assert.ErrorAs(t, err, &notFoundErr)
+
+	assert.NoError(t, db.InsertScheduledRun(ctx, model.ScheduledRun{
+		Name:          "sr",
+		TestSuiteName: "sn",
+		Schedule:      "@every 1m",
+	}))
+
+	assert.NoError(t, db.UpdateScheduledRun(ctx, model.ScheduledRun{
+		Name:          "sr",
+		TestSuiteName: "sn",
+		Schedule:      "@every 1h",
+		Paused:        true,
+	}))
+
+	schedules, err := db.LoadScheduledRuns(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, schedules, 1)
+	assert.Equal(t, "@every 1h", schedules[0].Schedule)
+	assert.True(t, schedules[0].Paused)
+}
+
+func testSaveAndLoadExternalTestSuites(t *testing.T, db storage.Storage) {
+	ctx := context.Background()
+
+	assert.NoError(t, db.SaveExternalTestSuite(ctx, model.ExternalTestSuite{
+		Name:  "external",
+		Tests: []string{"A"},
+	}))
+
+	assert.NoError(t, db.SaveExternalTestSuite(ctx, model.ExternalTestSuite{
+		Name:      "external",
+		Namespace: "ns",
+		Tests:     []string{"A", "B"},
+	}))
+
+	suites, err := db.LoadExternalTestSuites(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, suites, 1)
+	assert.Equal(t, "ns", suites[0].Namespace)
+	assert.Equal(t, []string{"A", "B"}, suites[0].Tests)
+}
+
+func testIdempotencyKey(t *testing.T, db storage.Storage) {
+	ctx := context.Background()
+
+	tsrID, err := db.InsertIdempotencyKey(ctx, "foo")
+	assert.NoError(t, err)
+	assert.Empty(t, tsrID)
+
+	var duplicateErr model.DuplicateError
+
+	tsrID, err = db.InsertIdempotencyKey(ctx, "foo")
+	assert.ErrorAs(t, err, &duplicateErr)
+	assert.Empty(t, tsrID)
+
+	err = db.UpdateIdempotencyKey(ctx, "foo", "my-app", 1)
+	assert.NoError(t, err)
+
+	tsrID, err = db.InsertIdempotencyKey(ctx, "foo")
+	assert.ErrorAs(t, err, &duplicateErr)
+	assert.Equal(t, tsrID, "suite-my-app-1")
+}
+
+func TestOpen(t *testing.T) {
+	db, err := storage.Open("badger://", 0, nil, slog.Default())
+	assert.NoError(t, err)
+	assert.IsType(t, &storage.BadgerStorage{}, db)
+	assert.NoError(t, db.Close())
+
+	_, err = storage.Open("mysql://localhost/handoff", 0, nil, slog.Default())
+	assert.ErrorContains(t, err, "unsupported database scheme")
+}
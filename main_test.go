@@ -7,6 +7,7 @@ import (
 	"math/rand"
 	"net/http"
 	"os"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -60,12 +61,104 @@ func Retry(x int) handoff.TestFunc {
 	}
 }
 
+// Alternate returns a test that passes and fails alternately, starting with a pass.
+func Alternate() handoff.TestFunc {
+	var calls atomic.Int32
+
+	return func(t handoff.TB) {
+		if calls.Add(1)%2 == 0 {
+			t.Fatal("every second call fails")
+		}
+	}
+}
+
+// FailOnCall returns a test that only fails when it is called for the nth time.
+func FailOnCall(n int32) handoff.TestFunc {
+	var calls atomic.Int32
+
+	return func(t handoff.TB) {
+		if calls.Add(1) == n {
+			t.Fatalf("failed on call %d", n)
+		}
+	}
+}
+
+// SlowOnCall returns a test that sleeps for `d` when it is called for the nth time.
+func SlowOnCall(n int32, d time.Duration) handoff.TestFunc {
+	var calls atomic.Int32
+
+	return func(t handoff.TB) {
+		if calls.Add(1) == n {
+			time.Sleep(d)
+		}
+	}
+}
+
+func CreateSpan(t handoff.TB) {
+	t.StartSpan("login").EndSpan()
+}
+
+func CreateManySpans(t handoff.TB) {
+	for i := range 21 {
+		t.StartSpan(fmt.Sprintf("request-%d", i)).EndSpan()
+	}
+}
+
+func SlowLog(t handoff.TB) {
+	time.Sleep(500 * time.Millisecond)
+
+	t.Log("streamed log")
+}
+
+func SetCorrelationID(t handoff.TB) {
+	t.SetValue("correlation-id", "checkout-42")
+}
+
 func Sleep(sleep time.Duration) handoff.TestFunc {
 	return func(t handoff.TB) {
 		time.Sleep(sleep)
 	}
 }
 
+func WaitForCancellation(t handoff.TB) {
+	<-t.Context().Done()
+
+	t.Fatal(t.Context().Err())
+}
+
+func ParallelWaitForCancellation(t handoff.TB) {
+	t.Parallel()
+
+	WaitForCancellation(t)
+}
+
+func ParallelA(t handoff.TB) {
+	t.Parallel()
+	time.Sleep(500 * time.Millisecond)
+}
+
+func ParallelB(t handoff.TB) {
+	t.Parallel()
+	time.Sleep(500 * time.Millisecond)
+}
+
+func ParallelC(t handoff.TB) {
+	t.Parallel()
+	time.Sleep(500 * time.Millisecond)
+}
+
+func ParallelSleep(sleep time.Duration) handoff.TestFunc {
+	return func(t handoff.TB) {
+		t.Parallel()
+		time.Sleep(sleep)
+	}
+}
+
+func SlowSequential(t handoff.TB) {
+	t.SetTimeout(time.Second)
+	time.Sleep(500 * time.Millisecond)
+}
+
 func Success(t handoff.TB) {
 	t.Log("Success")
 }
@@ -113,6 +206,37 @@ var defaultTestSuites = []handoff.TestSuite{
 			Success,
 		},
 	},
+	{
+		Name: "failing-teardown",
+		SetupWithContext: func(c *handoff.RunContext) error {
+			c.Log("creating tenant")
+			return nil
+		},
+		TeardownWithContext: func(c *handoff.RunContext) error {
+			c.Log("deleting tenant")
+			return errors.New("tenant not found")
+		},
+		Tests: []handoff.TestFunc{
+			Success,
+		},
+	},
+	{
+		Name:                "strict-failing-teardown",
+		FailOnTeardownError: true,
+		TeardownWithContext: func(c *handoff.RunContext) error {
+			c.Error("tenant not found")
+			return nil
+		},
+		Tests: []handoff.TestFunc{
+			Success,
+		},
+	},
+	{
+		Name: "slow-log",
+		Tests: []handoff.TestFunc{
+			SlowLog,
+		},
+	},
 	{
 		Name:            "needs-retry",
 		MaxTestAttempts: 2,
@@ -139,13 +263,69 @@ var defaultTestSuites = []handoff.TestSuite{
 			Fail,
 		},
 	},
+	{
+		Name:        "test-timeout",
+		TestTimeout: 100 * time.Millisecond,
+		Tests: []handoff.TestFunc{
+			WaitForCancellation,
+		},
+	},
+	{
+		Name:        "hanging-test",
+		TestTimeout: 100 * time.Millisecond,
+		Tests: []handoff.TestFunc{
+			Sleep(time.Minute),
+		},
+	},
+	{
+		Name:            "parallel",
+		Parallelism:     3,
+		MaxTestAttempts: 2,
+		Tests: []handoff.TestFunc{
+			ParallelA,
+			ParallelB,
+			ParallelC,
+			Retry(1),
+		},
+	},
+	{
+		Name:        "parallel-timeout",
+		Parallelism: 2,
+		TestTimeout: 200 * time.Millisecond,
+		Tests: []handoff.TestFunc{
+			ParallelSleep(50 * time.Millisecond),
+			SlowSequential,
+		},
+	},
+	{
+		Name: "cancellable",
+		Tests: []handoff.TestFunc{
+			WaitForCancellation,
+			Success,
+		},
+	},
+	{
+		Name: "partial-progress",
+		Tests: []handoff.TestFunc{
+			ParallelWaitForCancellation,
+			Success,
+		},
+	},
+	{
+		Name:    "suite-timeout",
+		Timeout: 100 * time.Millisecond,
+		Tests: []handoff.TestFunc{
+			WaitForCancellation,
+			Success,
+		},
+	},
 }
 
 func handoffInstance(
 	suites []handoff.TestSuite,
 	args []string,
+	options ...handoff.Option,
 ) *instance {
-	var options []handoff.Option
 
 	for _, ts := range suites {
 		options = append(options, handoff.WithTestSuite(ts))
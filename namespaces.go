@@ -0,0 +1,166 @@
+package handoff
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/raphi011/handoff/internal/model"
+)
+
+// namespaceSuites returns the (internal and external) test suites of a namespace sorted
+// by name.
+func (s *Server) namespaceSuites(namespace string) []model.TestSuite {
+	suites := []model.TestSuite{}
+
+	for _, ts := range s.readOnlyTestSuites {
+		if ts.Namespace == namespace {
+			suites = append(suites, ts)
+		}
+	}
+
+	for _, ts := range s.listExternalTestSuites() {
+		if ts.Namespace == namespace {
+			suites = append(suites, ts)
+		}
+	}
+
+	sort.Slice(suites, func(i, j int) bool {
+		return suites[i].Name < suites[j].Name
+	})
+
+	return suites
+}
+
+// namespaceSuiteNames returns the names of the test suites of a namespace, if
+// `suiteNames` is not empty only those suites are returned.
+func (s *Server) namespaceSuiteNames(namespace string, suiteNames []string) []string {
+	names := []string{}
+
+	for _, ts := range s.namespaceSuites(namespace) {
+		if len(suiteNames) == 0 || slices.Contains(suiteNames, ts.Name) {
+			names = append(names, ts.Name)
+		}
+	}
+
+	return names
+}
+
+// allSuiteNames returns the names of all (internal and external) test suites sorted by
+// name.
+func (s *Server) allSuiteNames() []string {
+	names := []string{}
+
+	for name := range s.readOnlyTestSuites {
+		names = append(names, name)
+	}
+
+	for _, ts := range s.listExternalTestSuites() {
+		names = append(names, ts.Name)
+	}
+
+	slices.Sort(names)
+
+	return names
+}
+
+// listNamespaces returns the names of all namespaces sorted by name. Test suites without
+// a namespace are not part of any namespace.
+func (s *Server) listNamespaces() []string {
+	namespaces := []string{}
+
+	for _, ts := range s.readOnlyTestSuites {
+		namespaces = append(namespaces, ts.Namespace)
+	}
+
+	for _, ts := range s.listExternalTestSuites() {
+		namespaces = append(namespaces, ts.Namespace)
+	}
+
+	slices.Sort(namespaces)
+	namespaces = slices.Compact(namespaces)
+
+	return slices.DeleteFunc(namespaces, func(ns string) bool { return ns == "" })
+}
+
+// loadNamespace returns the test suites of a namespace together with their latest run.
+func (s *Server) loadNamespace(ctx context.Context, namespace string) (model.Namespace, error) {
+	suites := s.namespaceSuites(namespace)
+	if len(suites) == 0 {
+		return model.Namespace{}, model.NotFoundError{}
+	}
+
+	ns := model.Namespace{Name: namespace, Suites: make([]model.NamespaceSuite, 0, len(suites))}
+
+	for _, ts := range suites {
+		suite := model.NamespaceSuite{
+			Name:      ts.Name,
+			Namespace: ts.Namespace,
+			Tests:     len(ts.Tests),
+			External:  ts.External,
+		}
+
+		runs, _, err := s.storage.ListTestSuiteRuns(ctx, ts.Name, model.TestSuiteRunFilter{}, model.Page{Limit: 1})
+		if err != nil {
+			return model.Namespace{}, fmt.Errorf("loading latest run of %s: %w", ts.Name, err)
+		}
+
+		if len(runs) > 0 {
+			suite.LatestRun = &runs[0]
+		}
+
+		ns.Suites = append(ns.Suites, suite)
+	}
+
+	return ns, nil
+}
+
+// startNamespaceRuns starts a run of every test suite of a namespace. External test
+// suites are skipped as they can not be run by handoff, as are test suites without tests
+// matching the filter and label selector. The idempotency key, if set, is made unique per
+// test suite. If a run can not be started, the runs started before are returned together
+// with the error.
+func (s *Server) startNamespaceRuns(namespace string, params model.RunParams) ([]model.TestSuiteRun, error) {
+	suites := slices.DeleteFunc(s.namespaceSuites(namespace), func(ts model.TestSuite) bool {
+		return ts.External
+	})
+	if len(suites) == 0 {
+		return nil, model.NotFoundError{}
+	}
+
+	suites = slices.DeleteFunc(suites, func(ts model.TestSuite) bool {
+		return len(ts.FilterTests(params.TestFilter, params.Labels)) == 0
+	})
+	if len(suites) == 0 {
+		param := "filter"
+		if params.Labels != nil {
+			param = "labels"
+		}
+
+		return nil, malformedRequestError{param: param, reason: "no tests match the given filter"}
+	}
+
+	if s.isShuttingDown() {
+		return nil, errors.New("shutting down")
+	}
+
+	runs := make([]model.TestSuiteRun, 0, len(suites))
+
+	for _, ts := range suites {
+		suiteParams := params
+		if params.IdempotencyKey != "" {
+			suiteParams.IdempotencyKey = params.IdempotencyKey + "/" + ts.Name
+		}
+
+		tsr, err := s.startNewTestSuiteRun(ts, suiteParams)
+		if err != nil {
+			return runs, fmt.Errorf("starting run of %s: %w", ts.Name, err)
+		}
+
+		runs = append(runs, tsr)
+	}
+
+	return runs, nil
+}
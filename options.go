@@ -2,7 +2,7 @@ package handoff
 
 func WithHook(p Hook) Option {
 	return func(s *Server) {
-		s.hooks.all = append(s.hooks.all, p)
+		s._userProvidedHooks = append(s._userProvidedHooks, p)
 	}
 }
 
@@ -11,3 +11,9 @@ func WithTestSuite(suite TestSuite) Option {
 		s._userProvidedTestSuites = append(s._userProvidedTestSuites, suite)
 	}
 }
+
+func WithScheduledRun(sr ScheduledRun) Option {
+	return func(s *Server) {
+		s._userProvidedSchedules = append(s._userProvidedSchedules, sr)
+	}
+}
@@ -0,0 +1,51 @@
+package handoff
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/raphi011/handoff/internal/model"
+)
+
+// rerunFailedTests starts a new run of a test suite with the tests whose latest attempt
+// failed in a finished run, the new run references the finished run as its parent. Tests
+// that were removed from the test suite since are not rerun.
+func (s *Server) rerunFailedTests(
+	ctx context.Context,
+	suiteName string,
+	runID int,
+	params model.RunParams,
+) (model.TestSuiteRun, error) {
+	ts, ok := s.readOnlyTestSuites[suiteName]
+	if !ok {
+		return model.TestSuiteRun{}, model.NotFoundError{}
+	}
+
+	parent, err := s.storage.LoadTestSuiteRun(ctx, suiteName, runID)
+	if err != nil {
+		return model.TestSuiteRun{}, err
+	}
+
+	if parent.Result == model.ResultPending {
+		return model.TestSuiteRun{}, conflictError{reason: "test suite run has not finished yet"}
+	}
+
+	tests := []string{}
+
+	for _, name := range parent.FailedTests() {
+		if _, ok := ts.Tests[name]; ok {
+			tests = append(tests, name)
+		}
+	}
+
+	if len(tests) == 0 {
+		return model.TestSuiteRun{}, conflictError{reason: fmt.Sprintf("test suite run %d has no failed tests to rerun", runID)}
+	}
+
+	params.Tests = tests
+	params.ParentRunID = parent.ID
+	params.MaxTestAttempts = parent.Params.MaxTestAttempts
+	params.Timeout = parent.Params.Timeout
+
+	return s.startNewTestSuiteRun(ts, params)
+}
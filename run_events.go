@@ -0,0 +1,67 @@
+package handoff
+
+import (
+	"sync"
+
+	"github.com/raphi011/handoff/internal/model"
+)
+
+// runEventBufferSize is the number of events that are buffered per subscriber,
+// subscribers that do not keep up are dropped.
+const runEventBufferSize = 256
+
+// runEventBroker publishes the progress of running test suite runs to the
+// subscribers of the respective run.
+type runEventBroker struct {
+	mu          sync.Mutex
+	subscribers map[string]map[chan model.RunEvent]struct{}
+}
+
+func newRunEventBroker() *runEventBroker {
+	return &runEventBroker{
+		subscribers: map[string]map[chan model.RunEvent]struct{}{},
+	}
+}
+
+// subscribe returns a channel that receives all events of a test suite run that are
+// published from now on. The returned function must be called to unsubscribe.
+func (b *runEventBroker) subscribe(suiteName string, runID int) (<-chan model.RunEvent, func()) {
+	key := activeRunKey(suiteName, runID)
+	events := make(chan model.RunEvent, runEventBufferSize)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.subscribers[key] == nil {
+		b.subscribers[key] = map[chan model.RunEvent]struct{}{}
+	}
+	b.subscribers[key][events] = struct{}{}
+
+	return events, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		delete(b.subscribers[key], events)
+		if len(b.subscribers[key]) == 0 {
+			delete(b.subscribers, key)
+		}
+	}
+}
+
+// publish sends an event to all subscribers of its run without blocking. The channel
+// of a subscriber whose buffer is full is closed and the subscriber removed.
+func (b *runEventBroker) publish(e model.RunEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	key := activeRunKey(e.SuiteName, e.RunID)
+
+	for events := range b.subscribers[key] {
+		select {
+		case events <- e:
+		default:
+			close(events)
+			delete(b.subscribers[key], events)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package handoff
+
+import (
+	"sync"
+
+	"github.com/raphi011/handoff/internal/metric"
+	"github.com/raphi011/handoff/internal/model"
+)
+
+// runQueue limits the number of test suite runs that are executed at the same time.
+// Runs exceeding the limits are queued and started in the order they were enqueued
+// once a running test suite run finishes.
+type runQueue struct {
+	// maxRunning is the maximum number of concurrently running test suite runs,
+	// 0 means unlimited.
+	maxRunning int
+	// maxRunningPerNamespace is the maximum number of concurrently running test
+	// suite runs within a namespace, 0 means unlimited.
+	maxRunningPerNamespace int
+
+	// start must start the test suite run without blocking and call `finished()`
+	// once it is done.
+	start func(ts model.TestSuite, tsr model.TestSuiteRun)
+
+	instance string
+
+	mu                  sync.Mutex
+	closed              bool
+	running             int
+	runningPerNamespace map[string]int
+	queued              []queuedRun
+}
+
+type queuedRun struct {
+	suite model.TestSuite
+	tsr   model.TestSuiteRun
+}
+
+func newRunQueue(
+	maxRunning, maxRunningPerNamespace int,
+	instance string,
+	start func(ts model.TestSuite, tsr model.TestSuiteRun),
+) *runQueue {
+	return &runQueue{
+		maxRunning:             maxRunning,
+		maxRunningPerNamespace: maxRunningPerNamespace,
+		start:                  start,
+		instance:               instance,
+		runningPerNamespace:    map[string]int{},
+	}
+}
+
+// enqueue adds a test suite run to the queue and starts it right away
+// if the limits allow it.
+func (q *runQueue) enqueue(ts model.TestSuite, tsr model.TestSuiteRun) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.queued = append(q.queued, queuedRun{suite: ts, tsr: tsr})
+	metric.TestSuitesQueued.WithLabelValues(q.instance, ts.Namespace, ts.Name).Inc()
+
+	q.dispatchLocked()
+}
+
+// finished must be called once a test suite run started by the queue is done.
+func (q *runQueue) finished(namespace string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.running--
+	q.runningPerNamespace[namespace]--
+
+	q.dispatchLocked()
+}
+
+// close stops the queue from starting new test suite runs. Queued runs are
+// still pending in the storage and are queued again on the next startup.
+func (q *runQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.closed = true
+}
+
+// remove removes a test suite run that has not been started yet from the
+// queue and returns true if it was found.
+func (q *runQueue) remove(suiteName string, runID int) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i, r := range q.queued {
+		if r.tsr.SuiteName == suiteName && r.tsr.ID == runID {
+			q.queued = append(q.queued[:i], q.queued[i+1:]...)
+			metric.TestSuitesQueued.WithLabelValues(q.instance, r.suite.Namespace, r.suite.Name).Dec()
+
+			return true
+		}
+	}
+
+	return false
+}
+
+// position returns the 1-based position of a test suite run in the queue
+// or 0 if it is not queued.
+func (q *runQueue) position(suiteName string, runID int) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i, r := range q.queued {
+		if r.tsr.SuiteName == suiteName && r.tsr.ID == runID {
+			return i + 1
+		}
+	}
+
+	return 0
+}
+
+// list returns the queued test suite runs in the order they will be started.
+func (q *runQueue) list() []model.TestSuiteRun {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	runs := make([]model.TestSuiteRun, 0, len(q.queued))
+
+	for i, r := range q.queued {
+		tsr := r.tsr.Copy()
+		tsr.QueuePosition = i + 1
+
+		runs = append(runs, tsr)
+	}
+
+	return runs
+}
+
+// dispatchLocked starts all queued runs that are within the limits. A run of a namespace
+// that reached its limit does not block runs of other namespaces queued after it.
+func (q *runQueue) dispatchLocked() {
+	if q.closed {
+		return
+	}
+
+	remaining := q.queued[:0]
+
+	for _, r := range q.queued {
+		ns := r.suite.Namespace
+
+		if (q.maxRunning > 0 && q.running >= q.maxRunning) ||
+			(q.maxRunningPerNamespace > 0 && q.runningPerNamespace[ns] >= q.maxRunningPerNamespace) {
+			remaining = append(remaining, r)
+			continue
+		}
+
+		q.running++
+		q.runningPerNamespace[ns]++
+		metric.TestSuitesQueued.WithLabelValues(q.instance, ns, r.suite.Name).Dec()
+
+		q.start(r.suite, r.tsr)
+	}
+
+	clear(q.queued[len(remaining):])
+	q.queued = remaining
+}
@@ -0,0 +1,338 @@
+package handoff
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/raphi011/handoff/internal/model"
+	"github.com/robfig/cron/v3"
+)
+
+// scheduleParser parses cron expressions the same way as the server's cron
+// instance does.
+var scheduleParser = cron.NewParser(
+	cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor,
+)
+
+func (s *Server) startStaticSchedules() error {
+	for _, sr := range s.readOnlySchedules {
+		sr.Static = true
+
+		if err := s.addSchedule(sr); err != nil {
+			return fmt.Errorf("schedule %q: %w", sr.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// startPersistedSchedules registers all schedules that were created through the api.
+// Schedules that are no longer valid, e.g. because their test suite was removed, are
+// skipped.
+func (s *Server) startPersistedSchedules(ctx context.Context) error {
+	schedules, err := s.storage.LoadScheduledRuns(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, sr := range schedules {
+		sr.Static = false
+
+		if err := s.addSchedule(sr); err != nil {
+			s.log.Warn("Cannot start persisted schedule", "schedule", sr.Name, "error", err)
+		}
+	}
+
+	return nil
+}
+
+// validateSchedule makes sure that a schedule references an existing test suite and
+// has a valid cron expression.
+func (s *Server) validateSchedule(sr model.ScheduledRun) (cron.Schedule, error) {
+	if sr.Name == "" {
+		return nil, malformedRequestError{param: "schedule-name", reason: "must not be empty"}
+	}
+
+	if sr.MaxRuns < 0 {
+		return nil, malformedRequestError{param: "max-runs", reason: "must not be negative"}
+	}
+
+	ts, ok := s.readOnlyTestSuites[sr.TestSuiteName]
+	if !ok {
+		return nil, malformedRequestError{param: "suite", reason: fmt.Sprintf("test suite %q not found", sr.TestSuiteName)}
+	}
+
+	if len(ts.FilterTests(sr.TestFilter, sr.Labels)) == 0 {
+		return nil, malformedRequestError{param: "filter", reason: "no tests match the given filter"}
+	}
+
+	schedule, err := scheduleParser.Parse(sr.Schedule)
+	if err != nil {
+		return nil, malformedRequestError{param: "schedule", reason: fmt.Sprintf("invalid cron expression: %v", err)}
+	}
+
+	return schedule, nil
+}
+
+// addSchedule validates a schedule and registers it with the cron instance unless
+// it is paused or has reached its maximum number of runs.
+func (s *Server) addSchedule(sr model.ScheduledRun) error {
+	schedule, err := s.validateSchedule(sr)
+	if err != nil {
+		return err
+	}
+
+	s.schedulesLock.Lock()
+	defer s.schedulesLock.Unlock()
+
+	if _, ok := s.schedules[sr.Name]; ok {
+		return conflictError{reason: fmt.Sprintf("schedule %q already exists", sr.Name)}
+	}
+
+	sr.EntryID = 0
+	if sr.Active() {
+		sr.EntryID = s.registerSchedule(sr.Name, schedule)
+	}
+
+	s.schedules[sr.Name] = sr
+
+	return nil
+}
+
+func (s *Server) registerSchedule(name string, schedule cron.Schedule) cron.EntryID {
+	return s.cron.Schedule(schedule, cron.FuncJob(func() {
+		s.runSchedule(name)
+	}))
+}
+
+// runSchedule is called by the cron instance and starts a new test suite run. Once
+// a schedule has reached its maximum number of runs it is unregistered.
+func (s *Server) runSchedule(name string) {
+	sr, ok := s.countScheduledRun(name)
+	if !ok {
+		return
+	}
+
+	ts := s.readOnlyTestSuites[sr.TestSuiteName]
+
+	_, err := s.startNewTestSuiteRun(ts, model.RunParams{
+		InitiatedBy:     "scheduled-run",
+		ScheduleName:    sr.Name,
+		TestFilter:      sr.TestFilter,
+		Labels:          sr.Labels,
+		MaxTestAttempts: ts.MaxTestAttempts,
+	})
+	if err != nil {
+		s.log.Error("starting new scheduled test suite run failed", "error", err, "test-suite", ts.Name, "schedule", name)
+	}
+}
+
+// countScheduledRun increments and persists the run count of a schedule. It returns
+// false if the schedule must not start a new run.
+func (s *Server) countScheduledRun(name string) (model.ScheduledRun, bool) {
+	s.schedulesLock.Lock()
+	defer s.schedulesLock.Unlock()
+
+	sr, ok := s.schedules[name]
+	if !ok || !sr.Active() {
+		return sr, false
+	}
+
+	sr.RunCount++
+
+	if sr.MaxRunsReached() {
+		s.log.Info("Schedule reached its maximum number of runs", "schedule", name, "max-runs", sr.MaxRuns)
+
+		s.cron.Remove(sr.EntryID)
+		sr.EntryID = 0
+	}
+
+	s.schedules[name] = sr
+
+	if !sr.Static {
+		if err := s.storage.UpdateScheduledRun(context.Background(), sr); err != nil {
+			s.log.Warn("Persisting schedule run count failed", "schedule", name, "error", err)
+		}
+	}
+
+	return sr, true
+}
+
+// createPersistedSchedule registers a new schedule and persists it so that it is
+// restored on the next startup.
+func (s *Server) createPersistedSchedule(ctx context.Context, sr model.ScheduledRun) error {
+	sr.Static = false
+
+	if err := s.addSchedule(sr); err != nil {
+		return err
+	}
+
+	s.schedulesLock.Lock()
+	defer s.schedulesLock.Unlock()
+
+	if err := s.storage.InsertScheduledRun(ctx, s.schedules[sr.Name]); err != nil {
+		s.unregisterScheduleLocked(sr.Name)
+
+		return fmt.Errorf("persisting schedule failed: %w", err)
+	}
+
+	return nil
+}
+
+// modifyScheduleDefinition changes the cron expression, test selection and run limit of a
+// schedule. Raising the run limit of a schedule that reached it re-enables the schedule.
+func (s *Server) modifyScheduleDefinition(
+	ctx context.Context,
+	name, schedule string,
+	filter *regexp.Regexp,
+	labels *model.LabelSelector,
+	maxRuns int,
+) error {
+	return s.modifySchedule(ctx, name, func(sr *model.ScheduledRun) {
+		sr.Schedule = schedule
+		sr.TestFilter = filter
+		sr.Labels = labels
+		sr.MaxRuns = maxRuns
+	})
+}
+
+// setSchedulePaused pauses or resumes a schedule, a paused schedule does not start new
+// test suite runs until it is resumed.
+func (s *Server) setSchedulePaused(ctx context.Context, name string, paused bool) error {
+	return s.modifySchedule(ctx, name, func(sr *model.ScheduledRun) {
+		sr.Paused = paused
+	})
+}
+
+// modifySchedule applies `modify` to a copy of a persisted schedule, re-registers
+// it with the cron instance and persists the change.
+func (s *Server) modifySchedule(ctx context.Context, name string, modify func(sr *model.ScheduledRun)) error {
+	s.schedulesLock.Lock()
+	defer s.schedulesLock.Unlock()
+
+	old, ok := s.schedules[name]
+	if !ok {
+		return model.NotFoundError{}
+	}
+
+	if old.Static {
+		return conflictError{reason: fmt.Sprintf("schedule %q is statically configured and cannot be modified", name)}
+	}
+
+	sr := old
+	modify(&sr)
+
+	schedule, err := s.validateSchedule(sr)
+	if err != nil {
+		return err
+	}
+
+	if err := s.storage.UpdateScheduledRun(ctx, sr); err != nil {
+		return fmt.Errorf("persisting schedule failed: %w", err)
+	}
+
+	if old.EntryID != 0 {
+		s.cron.Remove(old.EntryID)
+	}
+
+	sr.EntryID = 0
+	if sr.Active() {
+		sr.EntryID = s.registerSchedule(sr.Name, schedule)
+	}
+
+	s.schedules[name] = sr
+
+	return nil
+}
+
+// removeSchedule unregisters a schedule and removes it from the storage.
+func (s *Server) removeSchedule(ctx context.Context, name string) error {
+	s.schedulesLock.Lock()
+	defer s.schedulesLock.Unlock()
+
+	sr, ok := s.schedules[name]
+	if !ok {
+		return model.NotFoundError{}
+	}
+
+	if sr.Static {
+		return conflictError{reason: fmt.Sprintf("schedule %q is statically configured and cannot be deleted", name)}
+	}
+
+	if err := s.storage.DeleteScheduledRun(ctx, name); err != nil {
+		return err
+	}
+
+	s.unregisterScheduleLocked(name)
+
+	return nil
+}
+
+func (s *Server) unregisterScheduleLocked(name string) {
+	if sr, ok := s.schedules[name]; ok && sr.EntryID != 0 {
+		s.cron.Remove(sr.EntryID)
+	}
+
+	delete(s.schedules, name)
+}
+
+// listSchedules returns all static and persisted schedules sorted by name, including
+// their next and previous fire times.
+func (s *Server) listSchedules() []model.ScheduledRun {
+	s.schedulesLock.Lock()
+	defer s.schedulesLock.Unlock()
+
+	schedules := make([]model.ScheduledRun, 0, len(s.schedules))
+
+	for _, sr := range s.schedules {
+		schedules = append(schedules, s.withFireTimes(sr))
+	}
+
+	sort.Slice(schedules, func(i, j int) bool {
+		return schedules[i].Name < schedules[j].Name
+	})
+
+	return schedules
+}
+
+func (s *Server) getScheduleByName(name string) (model.ScheduledRun, error) {
+	s.schedulesLock.Lock()
+	defer s.schedulesLock.Unlock()
+
+	sr, ok := s.schedules[name]
+	if !ok {
+		return model.ScheduledRun{}, model.NotFoundError{}
+	}
+
+	return s.withFireTimes(sr), nil
+}
+
+// loadScheduleRuns returns all test suite runs that were started by a schedule, newest first.
+func (s *Server) loadScheduleRuns(ctx context.Context, name string) ([]model.TestSuiteRun, error) {
+	sr, err := s.getScheduleByName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := model.TestSuiteRunFilter{ScheduleName: name}
+
+	runs, _, err := s.storage.ListTestSuiteRuns(ctx, sr.TestSuiteName, filter, model.Page{})
+	if err != nil {
+		return nil, err
+	}
+
+	return runs, nil
+}
+
+func (s *Server) withFireTimes(sr model.ScheduledRun) model.ScheduledRun {
+	if sr.EntryID != 0 {
+		entry := s.cron.Entry(sr.EntryID)
+
+		sr.Next = entry.Next
+		sr.Prev = entry.Prev
+	}
+
+	return sr
+}
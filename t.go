@@ -3,7 +3,9 @@ package handoff
 import (
 	"context"
 	"fmt"
+	"runtime"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/raphi011/handoff/internal/model"
@@ -13,19 +15,46 @@ import (
 var _ model.TB = &T{}
 
 type T struct {
-	suiteName      string
-	testName       string
-	attempt        int
+	suiteName  string
+	suiteRunID int
+	testName   string
+	attempt    int
+	start      time.Time
+	ctx        context.Context
+	cancel     context.CancelCauseFunc
+
+	// runValues are the values of the run context that were set during the
+	// setup of the test suite run, they are read only.
+	runValues model.TestContext
+
+	// events publishes the progress of the test, it is nil if nobody
+	// is interested in it.
+	events *runEventBroker
+
+	// parallelWait is called by `Parallel()` and blocks until
+	// the test is allowed to continue running in parallel.
+	parallelWait func() error
+
+	// mu guards the fields below, a test that does not return after
+	// it has been cancelled can still modify them while the result
+	// is being collected.
+	mu             sync.Mutex
 	logs           strings.Builder
 	result         model.Result
 	runtimeContext model.TestContext
 	cleanupFunc    func()
 	softFailure    bool
-	ctx            context.Context
 	spans          []*model.Span
+	timeout        time.Duration
+	timeoutTimer   *time.Timer
+	parallel       bool
+	parallelSlot   bool
 }
 
 func (t *T) Cleanup(c func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
 	t.cleanupFunc = c
 }
 
@@ -40,6 +69,9 @@ func (t *T) Errorf(format string, args ...any) {
 }
 
 func (t *T) Fail() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
 	t.result = model.ResultFailed
 }
 
@@ -49,6 +81,9 @@ func (t *T) FailNow() {
 }
 
 func (t *T) Failed() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
 	return t.result == model.ResultFailed
 }
 
@@ -64,7 +99,12 @@ func (t *T) StartSpan(name string, kv ...any) *model.Span {
 		t.Fatalf("Error on StartSpan(): %v", err)
 	}
 
+	t.mu.Lock()
 	t.spans = append(t.spans, s)
+	t.mu.Unlock()
+
+	span := *s
+	t.publishEvent(model.RunEvent{Type: model.RunEventSpan, Span: &span})
 
 	return s
 }
@@ -77,11 +117,34 @@ func (t *T) Fatalf(format string, args ...any) {
 func (t *T) Helper() {}
 
 func (t *T) Log(args ...any) {
-	t.logs.WriteString(fmt.Sprint(args...) + "\n")
+	t.writeLog(fmt.Sprint(args...))
 }
 
 func (t *T) Logf(format string, args ...any) {
-	t.logs.WriteString(fmt.Sprintf(format, args...) + "\n")
+	t.writeLog(fmt.Sprintf(format, args...))
+}
+
+func (t *T) writeLog(line string) {
+	t.mu.Lock()
+	t.logs.WriteString(line + "\n")
+	t.mu.Unlock()
+
+	t.publishEvent(model.RunEvent{Type: model.RunEventLog, Log: line})
+}
+
+// publishEvent publishes an event of the test to the subscribers of the test suite run.
+func (t *T) publishEvent(e model.RunEvent) {
+	if t.events == nil {
+		return
+	}
+
+	e.SuiteName = t.suiteName
+	e.RunID = t.suiteRunID
+	e.TestName = t.testName
+	e.Attempt = t.attempt
+	e.Time = time.Now()
+
+	t.events.publish(e)
 }
 
 func (t *T) Name() string {
@@ -97,7 +160,10 @@ func (t *T) Skip(args ...any) {
 }
 
 func (t *T) SkipNow() {
+	t.mu.Lock()
 	t.result = model.ResultSkipped
+	t.mu.Unlock()
+
 	panic(skipTestErr{})
 }
 
@@ -107,6 +173,9 @@ func (t *T) Skipf(format string, args ...any) {
 }
 
 func (t *T) Skipped() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
 	return t.result == model.ResultSkipped
 }
 
@@ -118,11 +187,59 @@ func (t *T) TempDir() string {
 /* Handoff specific functions that are not part of the testing.TB interface */
 /* ------------------------------------------------------------------------ */
 
+// Context returns a context that is cancelled when the test times out,
+// the test suite run is cancelled or the server shuts down.
 func (t *T) Context() context.Context {
 	return t.ctx
 }
 
+// Parallel signals that this test is to be run in parallel with other parallel
+// tests of the suite. Like in the standard library the test is paused until all
+// other tests of the suite run have been started, at most `TestSuite.Parallelism`
+// tests run in parallel at the same time.
+func (t *T) Parallel() {
+	t.mu.Lock()
+	if t.parallel || t.parallelWait == nil {
+		t.mu.Unlock()
+		return
+	}
+	t.parallel = true
+	// waiting for other tests does not count towards the timeout, the
+	// timer is armed again once the test may continue.
+	t.stopTimeoutLocked()
+	t.mu.Unlock()
+
+	if err := t.parallelWait(); err != nil {
+		// the test run was cancelled while waiting, the result
+		// is determined by the cancellation cause.
+		runtime.Goexit()
+	}
+
+	t.mu.Lock()
+	t.parallelSlot = true
+	t.start = time.Now()
+	timeout := t.timeout
+	t.mu.Unlock()
+
+	t.SetTimeout(timeout)
+}
+
+// releaseParallelSlot returns true if the test held a parallel slot that
+// has to be freed.
+func (t *T) releaseParallelSlot() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	held := t.parallelSlot
+	t.parallelSlot = false
+
+	return held
+}
+
 func (t *T) SoftFailure() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
 	t.softFailure = true
 }
 
@@ -130,15 +247,34 @@ func (t *T) Attempt() int {
 	return t.attempt
 }
 
+// Value returns a value set by the test via `SetValue()` or, if the test has not set
+// it, by the setup of the test suite run.
 func (t *T) Value(key string) any {
-	return t.runtimeContext[key]
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if v, ok := t.runtimeContext[key]; ok {
+		return v
+	}
+
+	return t.runValues[key]
 }
 
 func (t *T) SetValue(key string, value any) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
 	t.runtimeContext[key] = value
 }
 
 func (t *T) Result() model.Result {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.resultLocked()
+}
+
+func (t *T) resultLocked() model.Result {
 	if t.result == "" {
 		return model.ResultPassed
 	}
@@ -146,26 +282,57 @@ func (t *T) Result() model.Result {
 	return t.result
 }
 
+// SetTimeout overrides the timeout of the test. The timeout is measured
+// from the start of the test, passing 0 disables it.
 func (t *T) SetTimeout(timeout time.Duration) {
-	// TODO
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.stopTimeoutLocked()
+
+	t.timeout = timeout
+
+	if timeout <= 0 || t.cancel == nil {
+		return
+	}
+
+	t.timeoutTimer = time.AfterFunc(time.Until(t.start.Add(timeout)), func() {
+		t.cancel(fmt.Errorf("%w after %s", errTestTimeout, timeout))
+	})
 }
 
-func (t *T) runTestCleanup() error {
-	if t.cleanupFunc == nil {
-		return nil
+func (t *T) stopTimeout() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.stopTimeoutLocked()
+}
+
+func (t *T) stopTimeoutLocked() {
+	if t.timeoutTimer != nil {
+		t.timeoutTimer.Stop()
+		t.timeoutTimer = nil
 	}
+}
+
+func (t *T) runTestCleanup() (err error) {
+	t.mu.Lock()
+	cleanupFunc := t.cleanupFunc
+	t.mu.Unlock()
 
-	var err error
+	if cleanupFunc == nil {
+		return nil
+	}
 
 	defer func() {
 		if r := recover(); r != nil {
-			err = fmt.Errorf("cleanup func panic'd: %v", err)
+			err = fmt.Errorf("cleanup func panic'd: %v", r)
 		}
 	}()
 
-	t.cleanupFunc()
+	cleanupFunc()
 
-	return err
+	return nil
 }
 
 // skipTestErr is passed to panic() to signal
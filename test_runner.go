@@ -2,22 +2,61 @@ package handoff
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"maps"
+	"sync"
 	"time"
 
 	"github.com/raphi011/handoff/internal/metric"
 	"github.com/raphi011/handoff/internal/model"
 )
 
+var (
+	// errTestTimeout is the cancellation cause of tests that exceeded their timeout.
+	errTestTimeout = errors.New("test timed out")
+	// errSuiteTimeout is the cancellation cause of test suite runs that exceeded their timeout.
+	errSuiteTimeout = errors.New("test suite run timed out")
+	// errServerShutdown is the cancellation cause of runs that were interrupted by a
+	// server shutdown, these are resumed on the next startup.
+	errServerShutdown = errors.New("server is shutting down")
+	// errRunCancelled is the cancellation cause of runs that were cancelled manually.
+	errRunCancelled = errors.New("test suite run was cancelled")
+)
+
+// testCancellationGracePeriod is how long we wait for a cancelled test to return
+// before we give up on it and continue with the next one.
+const testCancellationGracePeriod = time.Second
+
+// runTeardown runs the teardown of a suite and records its result and logs on the run.
+func (s *Server) runTeardown(suite model.TestSuite, tsr *model.TestSuiteRun, c *model.RunContext) {
+	if suite.Teardown == nil {
+		return
+	}
+
+	err := suite.SafeTeardown(c)
+
+	tsr.TeardownLogs = c.Logs()
+	tsr.TeardownResult = model.ResultPassed
+
+	if err != nil {
+		s.log.Warn("teardown of suite failed", "suite-name", suite.Name, "run-id", tsr.ID, "error", err)
+
+		tsr.TeardownLogs += fmt.Sprintf("teardown failed: %v", err)
+		tsr.TeardownResult = model.ResultFailed
+
+		metric.TeardownFailed(s.config.Instance, suite)
+	}
+}
+
 // runTestSuite executes a test suite run. It will run all tests that are either pending
-// or can be retried (attempt<maxattempts).
+// or can be retried (attempt<maxattempts). Cancelling `runCtx` interrupts the run,
+// this function must only be called by `runTestSuiteAsync()`.
 func (s *Server) runTestSuite(
+	runCtx context.Context,
 	suite model.TestSuite,
 	tsr model.TestSuiteRun,
 ) {
-	s.runningTestSuites.Add(1)
-	defer s.runningTestSuites.Done()
-
 	if tsr.Result == model.ResultPassed {
 		// nothing to do here
 		return
@@ -25,6 +64,12 @@ func (s *Server) runTestSuite(
 
 	ctx := context.Background()
 
+	if suite.Timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeoutCause(runCtx, suite.Timeout, fmt.Errorf("%w after %s", errSuiteTimeout, suite.Timeout))
+		defer cancel()
+	}
+
 	log := s.log.With("suite-name", suite.Name, "run-id", tsr.ID)
 
 	tsr.Start = time.Now()
@@ -35,12 +80,21 @@ func (s *Server) runTestSuite(
 		testSuitesRunning.Dec()
 	}()
 
-	if err := suite.SafeSetup(); err != nil {
+	runContext := model.NewRunContext(runCtx, suite.Name, tsr.ID, tsr.Context)
+
+	err := suite.SafeSetup(runContext)
+
+	tsr.Context = runContext.Values()
+	tsr.SetupLogs = runContext.Logs()
+
+	if err != nil {
 		log.Warn("setup of suite failed", "error", err)
+		metric.SetupFailed(s.config.Instance, suite)
+
 		end := time.Now()
 
 		tsr.Result = model.ResultFailed
-		tsr.SetupLogs = fmt.Sprintf("setup failed: %v", err)
+		tsr.SetupLogs += fmt.Sprintf("setup failed: %v", err)
 
 		for i := 0; i < len(tsr.TestResults); i++ {
 			tr := &tsr.TestResults[i]
@@ -55,31 +109,40 @@ func (s *Server) runTestSuite(
 
 	// skip if setup failed
 	if tsr.Result != model.ResultFailed {
-		for i := 0; i < len(tsr.TestResults); i++ {
-			tr := &tsr.TestResults[i]
+		run := &testSuiteRunState{tsr: tsr, values: tsr.Context}
 
-			if s.isShuttingDown() {
-				break
-			}
+		// persist the start of the run so that readers know it is being executed.
+		s.persistTestSuiteRunProgress(ctx, run)
 
-			if tr.Result != model.ResultPending {
-				continue
-			}
+		s.runTests(runCtx, suite, run)
 
-			s.runTest(ctx, suite, tsr, tr)
+		// all tests have returned (or were abandoned) at this point
+		// so there is no concurrent access to the run anymore.
+		tsr = run.tsr
 
-			if tsr.ShouldRetry(*tr) {
-				newAttempt := tr.NewAttempt()
+		// the teardown has to clean up even if the run was cancelled or timed out.
+		teardownContext := model.NewRunContext(context.WithoutCancel(runCtx), suite.Name, tsr.ID, tsr.Context)
 
-				tsr.TestResults = append(tsr.TestResults, newAttempt)
-			}
-		}
+		s.runTeardown(suite, &tsr, teardownContext)
 
-		if err := suite.SafeTeardown(); err != nil {
-			log.Warn("teardown of suite failed", "error", err)
+		tsr.Result = tsr.ResultFromTestResults()
+
+		if tsr.TeardownResult == model.ResultFailed && suite.FailOnTeardownError {
+			tsr.Result = model.ResultFailed
 		}
 
-		tsr.Result = tsr.ResultFromTestResults()
+		switch cause := context.Cause(runCtx); {
+		case errors.Is(cause, errSuiteTimeout):
+			log.Warn("test suite run timed out", "timeout", suite.Timeout)
+
+			skipPendingTests(&tsr, fmt.Sprintf("%v: skipped", cause))
+			tsr.Result = model.ResultFailed
+		case errors.Is(cause, errRunCancelled):
+			log.Info("test suite run cancelled")
+
+			skipPendingTests(&tsr, fmt.Sprintf("%v: skipped", cause))
+			tsr.Result = model.ResultCancelled
+		}
 	}
 
 	if tsr.Result != model.ResultPending {
@@ -87,6 +150,7 @@ func (s *Server) runTestSuite(
 	}
 	tsr.DurationInMS = tsr.TestSuiteDuration()
 	tsr.Flaky = tsr.IsFlaky()
+	tsr.Slow = s.isSlowRun(ctx, tsr)
 
 	s.hooks.notifyTestSuiteFinished(suite, tsr)
 
@@ -94,6 +158,14 @@ func (s *Server) runTestSuite(
 		log.Error("updating test suite run failed", "error", err)
 	}
 
+	s.events.publish(model.RunEvent{
+		Type:      model.RunEventRunFinished,
+		SuiteName: tsr.SuiteName,
+		RunID:     tsr.ID,
+		Time:      time.Now(),
+		Result:    tsr.Result,
+	})
+
 	if tsr.Result != model.ResultPending {
 		s.hooks.notifyTestSuiteFinishedAsync(suite, tsr)
 
@@ -101,59 +173,361 @@ func (s *Server) runTestSuite(
 	}
 }
 
+// skipPendingTests marks all tests of a run that have not been run yet as skipped.
+func skipPendingTests(tsr *model.TestSuiteRun, reason string) {
+	end := time.Now()
+
+	for _, tr := range tsr.PendingTests() {
+		tr.Result = model.ResultSkipped
+		tr.Logs = reason
+		tr.End = end
+	}
+}
+
+// runTests runs all pending tests of a test suite run in rounds. Every round runs the tests
+// that are pending at its start, failed tests that can be retried are run in the next round.
+func (s *Server) runTests(
+	ctx context.Context,
+	suite model.TestSuite,
+	run *testSuiteRunState,
+) {
+	for {
+		pending := run.pendingTests()
+
+		if len(pending) == 0 || s.isShuttingDown() || ctx.Err() != nil {
+			return
+		}
+
+		s.runTestRound(ctx, suite, run, pending)
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		run.scheduleRetries(pending)
+	}
+}
+
+// runTestRound starts the tests one after another and waits for each test to either return
+// or call `t.Parallel()` before starting the next one. Once all tests have been started the
+// parallel tests are resumed and run with at most `suite.Parallelism` tests at a time.
+func (s *Server) runTestRound(
+	ctx context.Context,
+	suite model.TestSuite,
+	run *testSuiteRunState,
+	tests []int,
+) {
+	group := newParallelGroup(suite.Parallelism)
+
+	var wg sync.WaitGroup
+
+	for _, i := range tests {
+		if s.isShuttingDown() || ctx.Err() != nil {
+			break
+		}
+
+		yielded := make(chan struct{})
+		yield := sync.OnceFunc(func() {
+			close(yielded)
+		})
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			defer yield()
+
+			s.runTest(ctx, suite, run, i, group, yield)
+		}()
+
+		<-yielded
+	}
+
+	close(group.release)
+
+	wg.Wait()
+}
+
+// parallelGroup coordinates the tests of a round that opted into running in parallel via
+// `t.Parallel()`. Like in the standard library parallel tests are paused until all tests
+// of the round have been started.
+type parallelGroup struct {
+	// release is closed once all tests of the round have been started.
+	release chan struct{}
+	// slots limits the number of parallel tests running at the same time.
+	slots chan struct{}
+}
+
+func newParallelGroup(parallelism int) *parallelGroup {
+	return &parallelGroup{
+		release: make(chan struct{}),
+		slots:   make(chan struct{}, max(parallelism, 1)),
+	}
+}
+
+// wait blocks until the parallel test may continue and has acquired a slot.
+func (g *parallelGroup) wait(ctx context.Context) error {
+	select {
+	case <-g.release:
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	}
+
+	select {
+	case g.slots <- struct{}{}:
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	}
+
+	if err := ctx.Err(); err != nil {
+		g.done()
+		return context.Cause(ctx)
+	}
+
+	return nil
+}
+
+// done frees the slot acquired by `wait()`.
+func (g *parallelGroup) done() {
+	<-g.slots
+}
+
+// testSuiteRunState holds a test suite run while its tests are executed. Parallel
+// tests update it concurrently, which is why it must only be accessed through its
+// methods until all tests have returned.
+type testSuiteRunState struct {
+	// values are the values of the run context, they are not modified once the
+	// tests are run.
+	values model.TestContext
+
+	mu  sync.Mutex
+	tsr model.TestSuiteRun
+}
+
+// pendingTests returns the indexes of all test runs that have not been run yet.
+func (r *testSuiteRunState) pendingTests() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	pending := []int{}
+
+	for i, tr := range r.tsr.TestResults {
+		if tr.Result == model.ResultPending {
+			pending = append(pending, i)
+		}
+	}
+
+	return pending
+}
+
+// scheduleRetries adds new attempts for all failed test runs that can be retried. If the
+// run repeats its tests, passed tests are run again unless a test of the round failed.
+func (r *testSuiteRunState) scheduleRetries(tests []int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.tsr.Params.Repeat > 0 {
+		for _, i := range tests {
+			if r.tsr.TestResults[i].Result == model.ResultFailed {
+				// stop at the first failure to keep its logs and spans for debugging.
+				return
+			}
+		}
+	}
+
+	for _, i := range tests {
+		tr := r.tsr.TestResults[i]
+
+		if r.tsr.ShouldRetry(tr) || r.tsr.ShouldRepeat(tr) {
+			r.tsr.TestResults = append(r.tsr.TestResults, tr.NewAttempt())
+		}
+	}
+}
+
+func (r *testSuiteRunState) testRun(i int) model.TestRun {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.tsr.TestResults[i].Copy()
+}
+
+// finishTestRun stores the result of a test run and returns a copy of the updated
+// test suite run.
+func (r *testSuiteRunState) finishTestRun(i int, tr model.TestRun) model.TestSuiteRun {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.tsr.TestResults[i] = tr
+
+	return r.tsr.Copy()
+}
+
+// persistTestSuiteRunProgress persists the current state of a test suite run that is
+// being executed. This way finished tests are not run again if the run is resumed after
+// a crash and readers of the api see the results of the tests that have finished so far.
+func (s *Server) persistTestSuiteRunProgress(ctx context.Context, run *testSuiteRunState) {
+	// the lock is held while persisting to make sure that an older state
+	// never overwrites a newer one.
+	run.mu.Lock()
+	defer run.mu.Unlock()
+
+	if err := s.storage.UpdateTestSuiteRun(ctx, run.tsr); err != nil {
+		s.log.Error("persisting test suite run progress failed", "suite-name", run.tsr.SuiteName, "run-id", run.tsr.ID, "error", err)
+	}
+}
+
 // runTest runs an individual test that is part of a test suite. This function must only be called
-// by `runTestSuite()`.
+// by `runTestRound()`, `yield` is called when the test calls `t.Parallel()`.
+//
+// The test function is run in its own goroutine so that a test that hangs and does not honour
+// the cancellation of `t.Context()` does not block the entire test suite run.
 func (s *Server) runTest(
-	_ context.Context,
+	ctx context.Context,
 	suite model.TestSuite,
-	testSuiteRun model.TestSuiteRun,
-	testRun *model.TestRun,
+	run *testSuiteRunState,
+	i int,
+	group *parallelGroup,
+	yield func(),
 ) {
-	t := T{
+	testRun := run.testRun(i)
+
+	testCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	start := time.Now()
+
+	t := &T{
 		attempt:        testRun.Attempt,
 		suiteName:      suite.Name,
+		suiteRunID:     run.tsr.ID,
 		testName:       testRun.Name,
+		events:         s.events,
+		start:          start,
+		ctx:            testCtx,
+		cancel:         cancel,
 		runtimeContext: map[string]any{},
+		runValues:      run.values,
+		parallelWait: func() error {
+			yield()
+			return group.wait(testCtx)
+		},
 	}
 
-	start := time.Now()
+	t.SetTimeout(run.tsr.Params.Timeout)
+	defer t.stopTimeout()
+
+	t.publishEvent(model.RunEvent{Type: model.RunEventTestStarted})
 
 	defer func() {
-		end := time.Now()
+		if t.releaseParallelSlot() {
+			group.done()
+		}
+	}()
+
+	finished := make(chan any, 1)
 
-		err := recover()
+	// interrupted is set by the test goroutine before it reports that the test has
+	// returned, it is true if the test was cancelled before it returned.
+	var interrupted bool
 
-		result := t.Result()
+	go func() {
+		var recovered any
 
-		metric.TestRunsTotal.WithLabelValues(s.config.Instance, suite.Namespace, suite.Name, string(result)).Inc()
+		defer func() {
+			if err := t.runTestCleanup(); err != nil {
+				s.log.Warn("test cleanup failed", "suite-name", suite.Name, "error", err)
+			}
 
-		s.hooks.notifyTestFinished(suite, testSuiteRun, testRun.Name, t.runtimeContext)
+			finished <- recovered
+		}()
 
-		logs := t.logs
+		defer func() {
+			recovered = recover()
+			interrupted = testCtx.Err() != nil
+		}()
 
-		if err != nil && t.result != model.ResultSkipped {
-			if _, ok := err.(failTestErr); !ok {
-				// this is an unexpected panic (does not originate from handoff)
-				logs.WriteString(fmt.Sprintf("%v\n", err))
-				result = model.ResultFailed
-			}
+		suite.Tests[testRun.Name](t)
+	}()
+
+	var (
+		err      any
+		returned bool
+	)
+
+	select {
+	case err = <-finished:
+		returned = true
+	case <-testCtx.Done():
+		// give tests that honour the context a chance to return.
+		select {
+		case err = <-finished:
+			returned = true
+		case <-time.After(testCancellationGracePeriod):
 		}
+	}
+
+	end := time.Now()
 
-		testRun.Start = start
-		testRun.End = end
-		testRun.DurationInMS = end.Sub(start).Milliseconds()
-		testRun.Result = result
-		testRun.SoftFailure = t.softFailure
-		testRun.Logs = logs.String()
-		testRun.Context = t.runtimeContext
-		testRun.Spans = t.spans
+	var cause error
+	if !returned || interrupted {
+		// the cancellation only affects the result of tests that were interrupted by
+		// it, not of tests that returned before the context was cancelled.
+		cause = context.Cause(testCtx)
+	}
 
-		s.hooks.notifyTestFinishedAync(suite, testSuiteRun, testRun.Name, t.runtimeContext)
+	if errors.Is(cause, errServerShutdown) {
+		// leave the test pending, it will be run again when the
+		// test suite run is resumed.
+		return
+	}
 
-		if err = t.runTestCleanup(); err != nil {
-			s.log.Warn("test cleanup failed", "suite-name", suite.Name, "error", err)
+	t.mu.Lock()
+	start = t.start
+	result := t.resultLocked()
+	logs := t.logs.String()
+	softFailure := t.softFailure
+	runtimeContext := maps.Clone(t.runtimeContext)
+	spans := append([]*model.Span{}, t.spans...)
+	t.mu.Unlock()
+
+	if err != nil && result != model.ResultSkipped {
+		if _, ok := err.(failTestErr); !ok {
+			// this is an unexpected panic (does not originate from handoff)
+			logs += fmt.Sprintf("%v\n", err)
+			result = model.ResultFailed
 		}
-	}()
+	}
+
+	if cause != nil {
+		logs += cause.Error() + "\n"
+		if !returned {
+			logs += fmt.Sprintf("test did not return within %s after it was cancelled\n", testCancellationGracePeriod)
+		}
+
+		if errors.Is(cause, errRunCancelled) {
+			result = model.ResultSkipped
+		} else {
+			result = model.ResultFailed
+		}
+	}
+
+	testRun.Start = start
+	testRun.End = end
+	testRun.DurationInMS = end.Sub(start).Milliseconds()
+	testRun.Result = result
+	testRun.SoftFailure = softFailure || testRun.Quarantined
+	testRun.Logs = logs
+	testRun.Context = runtimeContext
+	testRun.Spans = spans
+
+	metric.TestFinished(s.config.Instance, suite, run.tsr.Params, testRun)
+
+	testSuiteRun := run.finishTestRun(i, testRun)
+
+	s.persistTestSuiteRunProgress(context.Background(), run)
+
+	t.publishEvent(model.RunEvent{Type: model.RunEventTestFinished, Result: result})
 
-	suite.Tests[testRun.Name](&t)
+	s.hooks.notifyTestFinished(suite, testSuiteRun, testRun.Name, runtimeContext)
+	s.hooks.notifyTestFinishedAync(suite, testSuiteRun, testRun.Name, runtimeContext)
 }